@@ -0,0 +1,72 @@
+package main
+
+import (
+	"reflect"
+	"time"
+
+	"routers"
+)
+
+// destAddresses ... Host address of each router, indexed by RouterId, that injected envelopes are addressed to.
+// Nil unless addressing by IPv4 (-address)
+var destAddresses []routers.IPv4
+
+// settledAddresses ... Host address of each router once they stop changing, as negotiated blocks move while the
+// routers learn of each other. Gives up waiting after ten settle times
+func settledAddresses(in []chan<- interface{}) []routers.IPv4 {
+	addresses := hostAddresses(in)
+	for i := 0; i < 10; i++ {
+		time.Sleep(*settleTime)
+		next := hostAddresses(in)
+		if reflect.DeepEqual(addresses, next) {
+			break
+		}
+		addresses = next
+	}
+	return addresses
+}
+
+// hostAddresses ... Ask every router for its host address. Routers that don't reply are left with the zero
+// address, so envelopes to them are routed by RouterId alone
+func hostAddresses(in []chan<- interface{}) []routers.IPv4 {
+	addresses := routerBlocks(in)
+	for i := range addresses {
+		if addresses[i] != (routers.IPv4{}) {
+			addresses[i].Prefix = 32
+		}
+	}
+	return addresses
+}
+
+// routerBlocks ... Ask every router for its address, with the CIDR prefix of its block
+func routerBlocks(in []chan<- interface{}) []routers.IPv4 {
+	blocks := make([]routers.IPv4, len(in))
+	for _, state := range routers.QueryState(in, time.Second) {
+		blocks[state.ID] = state.Address
+	}
+	return blocks
+}
+
+// renumbered ... Number of routers whose address or block differs from {before}
+func renumbered(before []routers.IPv4, states []routers.RouterState) int {
+	count := 0
+	for _, state := range states {
+		if int(state.ID) < len(before) && before[state.ID] != state.Address {
+			count++
+		}
+	}
+	return count
+}
+
+// overlappingBlocks ... Number of pairs of routers whose CIDR blocks overlap
+func overlappingBlocks(states []routers.RouterState) int {
+	overlaps := 0
+	for i, a := range states {
+		for _, b := range states[i+1:] {
+			if a.Address.Overlaps(b.Address) {
+				overlaps++
+			}
+		}
+	}
+	return overlaps
+}
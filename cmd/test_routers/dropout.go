@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+
+	"routers"
+)
+
+// dropouts ... Routers that fail during the test, and so neither send nor receive test traffic, alongside the links
+// taken down and brought up between the routers that stay up
+type dropouts struct {
+	Routers []routers.RouterId
+	Cut     []routers.Link
+	Added   []routers.Link
+	dead    map[routers.RouterId]bool
+}
+
+// chooseDropouts ... Pick {count} distinct routers to fail, leaving at least two in the network
+func chooseDropouts(routerCount int, count uint, rng *rand.Rand) (dropouts, error) {
+	d := dropouts{Routers: make([]routers.RouterId, 0, count), dead: make(map[routers.RouterId]bool)}
+	if count == 0 {
+		return d, nil
+	}
+	if int(count) > routerCount-2 {
+		return d, fmt.Errorf("cannot drop out %v of %v routers, at least two must remain", count, routerCount)
+	}
+	for _, i := range rng.Perm(routerCount)[:count] {
+		d.Routers = append(d.Routers, routers.RouterId(i))
+		d.dead[routers.RouterId(i)] = true
+	}
+	return d, nil
+}
+
+// relink ... Pick {count} links to take down, each leaving the routers that stay up connected, and {count} links to
+// bring up between routers that stay up and aren't yet neighbours
+func (d *dropouts) relink(t routers.Template, count uint, rng *rand.Rand) error {
+	adjacent := make(map[routers.Link]bool)
+	for from, ns := range t {
+		for _, to := range ns {
+			adjacent[routers.Link{From: routers.RouterId(from), To: to}] = true
+		}
+	}
+	links := make([]routers.Link, 0, len(adjacent)/2)
+	for l := range adjacent {
+		if l.From < l.To && !d.dead[l.From] && !d.dead[l.To] {
+			links = append(links, l)
+		}
+	}
+	sort.Slice(links, func(i, j int) bool {
+		return links[i].From < links[j].From || (links[i].From == links[j].From && links[i].To < links[j].To)
+	})
+	for _, i := range rng.Perm(len(links)) {
+		if uint(len(d.Cut)) == count {
+			break
+		}
+		l := links[i]
+		delete(adjacent, l)
+		delete(adjacent, routers.Link{From: l.To, To: l.From})
+		if d.connected(len(t), adjacent) {
+			d.Cut = append(d.Cut, l)
+		} else {
+			adjacent[l], adjacent[routers.Link{From: l.To, To: l.From}] = true, true
+		}
+	}
+	if uint(len(d.Cut)) < count {
+		return fmt.Errorf("cannot take down %v links without disconnecting the network", count)
+	}
+	for _, i := range rng.Perm(len(t) * len(t)) {
+		if uint(len(d.Added)) == count {
+			break
+		}
+		l := routers.Link{From: routers.RouterId(i / len(t)), To: routers.RouterId(i % len(t))}
+		if l.From >= l.To || d.dead[l.From] || d.dead[l.To] || adjacent[l] {
+			continue
+		}
+		d.Added = append(d.Added, l)
+	}
+	if uint(len(d.Added)) < count {
+		return fmt.Errorf("cannot bring up %v new links, too few routers aren't already neighbours", count)
+	}
+	return nil
+}
+
+// connected ... Check the routers that stay up can all reach each other over the links
+func (d dropouts) connected(count int, adjacent map[routers.Link]bool) bool {
+	neighbours := make(map[routers.RouterId][]routers.RouterId)
+	for l := range adjacent {
+		if !d.dead[l.From] && !d.dead[l.To] {
+			neighbours[l.From] = append(neighbours[l.From], l.To)
+		}
+	}
+	start, alive := routers.RouterId(0), 0
+	for id := 0; id < count; id++ {
+		if !d.dead[routers.RouterId(id)] {
+			start = routers.RouterId(id)
+			alive++
+		}
+	}
+	seen := map[routers.RouterId]bool{start: true}
+	queue := []routers.RouterId{start}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, n := range neighbours[current] {
+			if !seen[n] {
+				seen[n] = true
+				queue = append(queue, n)
+			}
+		}
+	}
+	return len(seen) == alive
+}
+
+// alive ... Keep only the flows between routers that stay up
+func (d dropouts) alive(flows []flow) []flow {
+	kept := make([]flow, 0, len(flows))
+	for _, f := range flows {
+		if !d.dead[f.Source] && !d.dead[f.Dest] {
+			kept = append(kept, f)
+		}
+	}
+	return kept
+}
+
+// fail ... Drop the chosen routers out of the network and take down and bring up the chosen links, without
+// waiting for them
+func (d dropouts) fail(in []chan<- interface{}) {
+	for _, id := range d.Routers {
+		go func(r chan<- interface{}) {
+			r <- routers.Dropout{}
+		}(in[id])
+	}
+	for _, l := range d.Cut {
+		go routers.Disconnect(in, l.From, l.To)
+	}
+	for _, l := range d.Added {
+		go routers.Connect(in, l.From, l.To)
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"log"
+	"time"
+
+	"routers"
+)
+
+// forwardingCost ... Forwarding decisions made across the network by label and by routing table lookup, with the
+// mean time each took
+type forwardingCost struct {
+	LabelSwitched uint64        `json:"label_switched"`
+	Routed        uint64        `json:"routed"`
+	LabelTime     time.Duration `json:"mean_label_time_ns"`
+	RouteTime     time.Duration `json:"mean_route_time_ns"`
+	Labels        int           `json:"labels"`
+	// Time the label switched decisions would have added had they been route lookups
+	Saved time.Duration `json:"time_saved_ns"`
+}
+
+// collectForwarding ... Sum the forwarding counters of every router
+func collectForwarding(states []routers.RouterState) forwardingCost {
+	var total routers.ForwardingCounters
+	for _, s := range states {
+		total.LabelSwitched += s.Forwarding.LabelSwitched
+		total.LabelTime += s.Forwarding.LabelTime
+		total.Routed += s.Forwarding.Routed
+		total.RouteTime += s.Forwarding.RouteTime
+		total.Labels += s.Forwarding.Labels
+	}
+	f := forwardingCost{LabelSwitched: total.LabelSwitched, Routed: total.Routed, Labels: total.Labels}
+	if total.LabelSwitched > 0 {
+		f.LabelTime = total.LabelTime / time.Duration(total.LabelSwitched)
+	}
+	if total.Routed > 0 {
+		f.RouteTime = total.RouteTime / time.Duration(total.Routed)
+	}
+	if f.RouteTime > f.LabelTime {
+		f.Saved = time.Duration(total.LabelSwitched) * (f.RouteTime - f.LabelTime)
+	}
+	return f
+}
+
+// printForwarding ... Log how the routers made their forwarding decisions
+func printForwarding(f forwardingCost) {
+	log.Println("| -> Forwarding")
+	log.Printf("|    Label Switched: %v, mean %v\n", f.LabelSwitched, f.LabelTime)
+	log.Printf("|    Routed: %v, mean %v\n", f.Routed, f.RouteTime)
+	log.Printf("|    Labels: %v, Time Saved: %v\n", f.Labels, f.Saved)
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"routers"
+)
+
+// generators ... Supported values of the generator (-g) flag
+var generators = []string{"Burst", "Constant", "Poisson", "On_Off"}
+
+// isGenerator ... Check if the given name is a supported traffic generator
+func isGenerator(name string) bool {
+	for _, g := range generators {
+		if g == name {
+			return true
+		}
+	}
+	return false
+}
+
+// TrafficMatrix ... Envelope injection rates (per second) indexed by [source][destination]
+type TrafficMatrix [][]float64
+
+// loadTrafficMatrix ... Read a whitespace separated source x destination rate matrix, ignoring # comments
+func loadTrafficMatrix(path string, routerCount int) (TrafficMatrix, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	matrix := make(TrafficMatrix, 0, routerCount)
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if i := strings.Index(text, "#"); i >= 0 {
+			text = text[:i]
+		}
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != routerCount {
+			return nil, fmt.Errorf("%s:%v: expected %v rates, found %v", path, line, routerCount, len(fields))
+		}
+		row := make([]float64, len(fields))
+		for i, field := range fields {
+			rate, err := strconv.ParseFloat(field, 64)
+			if err != nil || rate < 0 {
+				return nil, fmt.Errorf("%s:%v: invalid rate %q", path, line, field)
+			}
+			row[i] = rate
+		}
+		matrix = append(matrix, row)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(matrix) != routerCount {
+		return nil, fmt.Errorf("%s: expected %v rows, found %v", path, routerCount, len(matrix))
+	}
+	return matrix, nil
+}
+
+// stream ... A single traffic source injecting envelopes according to an arrival process
+type stream struct {
+	source routers.RouterId
+	dests  []routers.RouterId
+	rate   float64
+	rng    *rand.Rand
+	on     bool
+	until  time.Time
+}
+
+// period ... Randomised length of the stream's next on or off period
+func (s *stream) period(on bool) time.Duration {
+	mean := *offPeriod
+	if on {
+		mean = *onPeriod
+	}
+	return time.Duration(s.rng.ExpFloat64() * float64(mean))
+}
+
+// interArrival ... Time until the stream's next envelope under the chosen arrival process
+func (s *stream) interArrival(generator string, now time.Time) time.Duration {
+	mean := float64(time.Second) / s.rate
+	switch generator {
+	case "Constant":
+		return time.Duration(mean)
+	case "On_Off":
+		// Alternate exponentially distributed on and off periods, sending as Poisson while on
+		if s.until.IsZero() {
+			s.on, s.until = true, now.Add(s.period(true))
+		}
+		t := now
+		for {
+			if s.on {
+				candidate := t.Add(time.Duration(s.rng.ExpFloat64() * mean))
+				if candidate.Before(s.until) {
+					return candidate.Sub(now)
+				}
+			}
+			t = s.until
+			s.on = !s.on
+			s.until = t.Add(s.period(s.on))
+		}
+	default:
+		return time.Duration(s.rng.ExpFloat64() * mean)
+	}
+}
+
+// buildStreams ... Create the traffic sources from a traffic matrix, or from the test mode at a uniform rate
+func buildStreams(template routers.Template, matrix TrafficMatrix, rng *rand.Rand, failures dropouts) ([]*stream, error) {
+	streams := make([]*stream, 0)
+	if matrix != nil {
+		for s, row := range matrix {
+			for d, rate := range row {
+				if rate <= 0 || failures.dead[routers.RouterId(s)] || failures.dead[routers.RouterId(d)] {
+					continue
+				}
+				streams = append(streams, &stream{
+					source: routers.RouterId(s),
+					dests:  []routers.RouterId{routers.RouterId(d)},
+					rate:   rate,
+					rng:    rand.New(rand.NewSource(rng.Int63())),
+				})
+			}
+		}
+		return streams, nil
+	}
+	if *rate <= 0 {
+		return nil, fmt.Errorf("generator rate must be positive (have %v)", *rate)
+	}
+	flows, err := buildFlows(*mode, template, rng)
+	if err != nil {
+		return nil, err
+	}
+	flows = failures.alive(flows)
+	bySource := make(map[routers.RouterId]*stream)
+	for _, f := range flows {
+		s, ok := bySource[f.Source]
+		if !ok {
+			s = &stream{
+				source: f.Source,
+				rate:   *rate,
+				rng:    rand.New(rand.NewSource(rng.Int63())),
+			}
+			bySource[f.Source] = s
+			streams = append(streams, s)
+		}
+		s.dests = append(s.dests, f.Dest)
+	}
+	return streams, nil
+}
+
+// runGenerated ... Inject envelopes over the test duration using the chosen arrival process, then drain the network.
+// With {fail} set the dropouts fail once injection has started
+func runGenerated(template routers.Template, in []chan<- interface{}, out <-chan routers.Envelope, repeat uint, rng *rand.Rand, matrix TrafficMatrix, failures dropouts, fail bool) roundResult {
+	streams, err := buildStreams(template, matrix, rng, failures)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	var lock sync.Mutex
+	pending := make(map[msgKey]envelopeRecord)
+	sent := 0
+
+	start := time.Now()
+	deadline := start.Add(*duration)
+	stop := make(chan struct{})
+	defer close(stop)
+	var wg sync.WaitGroup
+	for _, s := range streams {
+		wg.Add(1)
+		go func(s *stream) {
+			defer wg.Done()
+			seqs := make(map[routers.RouterId]uint)
+			next := start
+			for {
+				next = next.Add(s.interArrival(*generator, next))
+				if !next.Before(deadline) {
+					return
+				}
+				time.Sleep(time.Until(next))
+				dest := s.dests[s.rng.Intn(len(s.dests))]
+				key := msgKey{Round: repeat, Source: s.source, Dest: dest, Seq: seqs[dest]}
+				seqs[dest]++
+
+				lock.Lock()
+				pending[key] = envelopeRecord{Repeat: repeat, Source: s.source, Dest: dest}
+				sent++
+				lock.Unlock()
+				inject(in, key.envelope(), stop)
+			}
+		}(s)
+	}
+	if fail {
+		failures.fail(in)
+	}
+	generated := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(generated)
+	}()
+
+	result := roundResult{envelopes: make([]envelopeRecord, 0)}
+	var drained <-chan time.Time
+	for {
+		select {
+		case envelope := <-out:
+			k, ok := envelope.Message.(msgKey)
+			if !ok {
+				log.Printf("Unexpected message body %g! Make sure you aren't editing Envelopes.", envelope.Message)
+				continue
+			}
+			lock.Lock()
+			record, ok := pending[k]
+			delete(pending, k)
+			lock.Unlock()
+			if !ok {
+				if k.Round == repeat {
+					log.Printf("Unexpected message value %v! Make sure you aren't duplicating Envelopes.", k)
+				}
+				continue
+			}
+			record.Hops = envelope.Hops
+			record.Cost = envelope.Cost
+			record.Latency = envelope.Delivered.Sub(envelope.Injected)
+			record.Rerouted = envelope.Rerouted
+			result.envelopes = append(result.envelopes, record)
+		case <-generated:
+			generated = nil
+			drained = time.After(*drainTimeout)
+		case <-drained:
+			lock.Lock()
+			result.lost = len(pending)
+			lock.Unlock()
+			result.sent = sent
+			result.duration = *duration
+			return result
+		}
+		if generated == nil {
+			lock.Lock()
+			remaining := len(pending)
+			lock.Unlock()
+			if remaining == 0 {
+				result.sent = sent
+				result.duration = *duration
+				return result
+			}
+		}
+	}
+}
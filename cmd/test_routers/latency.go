@@ -0,0 +1,159 @@
+package main
+
+import (
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"routers"
+)
+
+// bucket ... A single histogram bin covering [Lower, Upper)
+type bucket struct {
+	Lower time.Duration `json:"lower_ns"`
+	Upper time.Duration `json:"upper_ns"`
+	Count int           `json:"count"`
+}
+
+// latencyStats ... End-to-end envelope latency distribution and its breakdowns
+type latencyStats struct {
+	Overall       summary                      `json:"overall_ns"`
+	Histogram     []bucket                     `json:"histogram"`
+	ByHops        map[uint]summary             `json:"by_hops_ns"`
+	BySource      map[routers.RouterId]summary `json:"by_source_ns"`
+	ByDestination map[routers.RouterId]summary `json:"by_destination_ns"`
+}
+
+// latencyBreakdown ... Summarise envelope latencies overall and by hop count, source and destination
+func latencyBreakdown(records []envelopeRecord) latencyStats {
+	all := make([]float64, len(records))
+	byHops := make(map[uint][]float64)
+	bySource := make(map[routers.RouterId][]float64)
+	byDest := make(map[routers.RouterId][]float64)
+	for i, r := range records {
+		v := float64(r.Latency)
+		all[i] = v
+		byHops[r.Hops] = append(byHops[r.Hops], v)
+		bySource[r.Source] = append(bySource[r.Source], v)
+		byDest[r.Dest] = append(byDest[r.Dest], v)
+	}
+	stats := latencyStats{
+		Overall:       summarise(all),
+		Histogram:     histogram(all),
+		ByHops:        make(map[uint]summary, len(byHops)),
+		BySource:      make(map[routers.RouterId]summary, len(bySource)),
+		ByDestination: make(map[routers.RouterId]summary, len(byDest)),
+	}
+	for h, samples := range byHops {
+		stats.ByHops[h] = summarise(samples)
+	}
+	for id, samples := range bySource {
+		stats.BySource[id] = summarise(samples)
+	}
+	for id, samples := range byDest {
+		stats.ByDestination[id] = summarise(samples)
+	}
+	return stats
+}
+
+// histogram ... Bin latency samples into power of two buckets starting at one microsecond
+func histogram(samples []float64) []bucket {
+	buckets := make([]bucket, 0)
+	if len(samples) == 0 {
+		return buckets
+	}
+	max := 0.0
+	for _, v := range samples {
+		if v > max {
+			max = v
+		}
+	}
+	lower := time.Duration(0)
+	upper := time.Microsecond
+	for {
+		buckets = append(buckets, bucket{Lower: lower, Upper: upper})
+		if float64(upper) > max {
+			break
+		}
+		lower, upper = upper, upper*2
+	}
+	for _, v := range samples {
+		for i := range buckets {
+			if v < float64(buckets[i].Upper) {
+				buckets[i].Count++
+				break
+			}
+		}
+	}
+	return buckets
+}
+
+// printLatency ... Log the latency distribution, histogram and per hop breakdown (per router when verbose)
+func printLatency(stats latencyStats, verbose bool) {
+	log.Println("| -> Latency")
+	log.Printf("|    Minimum: %v, Maximum: %v\n", time.Duration(stats.Overall.Min), time.Duration(stats.Overall.Max))
+	log.Printf("|    Mean: %v, Std Dev: %v\n", time.Duration(stats.Overall.Mean), time.Duration(stats.Overall.StdDev))
+	log.Printf("|    Median: %v, P95: %v, P99: %v\n",
+		time.Duration(stats.Overall.Median),
+		time.Duration(stats.Overall.P95),
+		time.Duration(stats.Overall.P99))
+
+	log.Println("| -> Latency Histogram")
+	largest := 0
+	for _, b := range stats.Histogram {
+		if b.Count > largest {
+			largest = b.Count
+		}
+	}
+	for _, b := range stats.Histogram {
+		width := 0
+		if largest > 0 {
+			width = b.Count * 40 / largest
+		}
+		log.Printf("|    %10v - %-10v %6v %s\n", b.Lower, b.Upper, b.Count, strings.Repeat("#", width))
+	}
+
+	log.Println("| -> Latency By Hops")
+	hops := make([]uint, 0, len(stats.ByHops))
+	for h := range stats.ByHops {
+		hops = append(hops, h)
+	}
+	sort.Slice(hops, func(i, j int) bool { return hops[i] < hops[j] })
+	for _, h := range hops {
+		printLatencyRow("Hops", uint(h), stats.ByHops[h])
+	}
+
+	if !verbose {
+		return
+	}
+	log.Println("| -> Latency By Source")
+	for _, id := range sortedIds(stats.BySource) {
+		printLatencyRow("Source", uint(id), stats.BySource[id])
+	}
+	log.Println("| -> Latency By Destination")
+	for _, id := range sortedIds(stats.ByDestination) {
+		printLatencyRow("Destination", uint(id), stats.ByDestination[id])
+	}
+}
+
+// printLatencyRow ... Log a single breakdown entry of the latency distribution
+func printLatencyRow(label string, key uint, s summary) {
+	log.Printf("|    %v %v: count %v, mean %v, median %v, p95 %v\n",
+		label,
+		key,
+		s.Count,
+		time.Duration(s.Mean),
+		time.Duration(s.Median),
+		time.Duration(s.P95))
+}
+
+// sortedIds ... Ascending router IDs present in a breakdown
+func sortedIds(m map[routers.RouterId]summary) []routers.RouterId {
+	ids := make([]routers.RouterId, 0, len(m))
+	for id := range m {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
@@ -5,158 +5,520 @@ import (
 	"fmt"
 	"log"
 	"math/big"
+	"math/rand"
 	"os"
+	"strings"
 	"time"
 
 	"routers"
+	"routers/topology"
 )
 
 var (
-	topology = flag.String("t", "Mesh", "`topology` (by size: Line, Ring, Star, Fully_Connected; "+
-		"by dimension and size: Mesh, Torus; by dimension: Hypercube, Cube_Connected_Cycles, Butterfly, Wrap_Around_Butterfly)")
+	topologyName = flag.String("t", "Mesh", "`topology` (by size: Line, Ring, Star, Fully_Connected; "+
+		"by dimension and size: Mesh, Torus; by dimension: Hypercube, Cube_Connected_Cycles, Butterfly, Wrap_Around_Butterfly; "+
+		"random by size: Erdos_Renyi, Barabasi_Albert, Watts_Strogatz, Random_Regular, Random_Geometric; "+
+		"from a file: file:path with .txt/.edges, .json, .graphml or .dot extension)")
 	size             = flag.Uint("s", 20, "size")
 	dimension        = flag.Uint("d", 3, "dimension")
+	probability      = flag.Float64("p", 0.3, "link probability (Erdos_Renyi) or rewiring probability (Watts_Strogatz)")
+	degree           = flag.Uint("k", 2, "attachments (Barabasi_Albert), lattice degree (Watts_Strogatz) or degree (Random_Regular)")
+	radius           = flag.Float64("radius", 0.3, "link radius within the unit square (Random_Geometric)")
+	connected        = flag.Bool("connected", true, "guarantee random topologies are connected")
 	printConnections = flag.Bool("c", false, "print connections")
 	// printDistances   = flag.Bool("i", true, "print distances")
 	settleTime = flag.Duration("w", time.Second/10, "routers settle time")
-	//timeout          = flag.Duration("o", time.Second/10, "comms timeout")
-	mode     = flag.String("m", "One_To_All", "`mode` (One_To_All, All_To_One)")
-	dropouts = flag.Uint("x", 0, "dropouts")
-	repeats  = flag.Uint("r", 10, "repeats")
-	force    = flag.Bool("f", false, "force the creation of a large number of routers")
-	logging  = flag.String("l", "normal", "`logging` (none, normal, verbose)")
+	mode       = flag.String("m", "One_To_All", "`mode` (One_To_All, All_To_One, All_To_All, Random_Pairs, Permutation, "+
+		"Bit_Reversal, Transpose, Nearest_Neighbour, Broadcast, Multicast, Anycast)")
+	dropoutCount = flag.Uint("x", 0, "routers to drop out of the network while the first round's envelopes are in flight")
+	repeats      = flag.Uint("r", 10, "repeats")
+	rebuild      = flag.Bool("n", false, "rebuild the network for every repeat")
+	force        = flag.Bool("f", false, "force the creation of a large number of routers")
+	logging      = flag.String("l", "normal", "`logging` (none, normal, verbose)")
+	output       = flag.String("o", "", "write results to `file` (format by extension: .json, .csv)")
+	seed         = flag.Int64("seed", time.Now().UnixNano(), "random `seed`")
+
+	generator    = flag.String("g", "Burst", "traffic `generator` (Burst, Constant, Poisson, On_Off)")
+	rate         = flag.Float64("rate", 1000, "envelopes per second from each source for timed generators")
+	duration     = flag.Duration("duration", time.Second, "injection period of timed generators")
+	onPeriod     = flag.Duration("on", 50*time.Millisecond, "mean on period of the On_Off generator")
+	offPeriod    = flag.Duration("off", 50*time.Millisecond, "mean off period of the On_Off generator")
+	matrixFile   = flag.String("tm", "", "traffic matrix `file` of source x destination rates (overrides -m and -rate)")
+	drainTimeout = flag.Duration("drain", time.Second, "time to wait for outstanding envelopes once injection stops")
+
+	multipath = flag.String("multipath", "none", "equal cost multipath `mode` (none, flow, packet)")
+	flowCount = flag.Uint("flows", 1, "flow labels used between each source and destination")
+
+	fastReroute      = flag.Bool("frr", false, "fast reroute to loop free alternates when a neighbour drops out")
+	convergenceDelay = flag.Duration("convergence", 0, "time a router keeps its old routes after its routing table changes, as if recomputing them")
+
+	routing = flag.String("routing", "table", "routing `mode` (table; dimension_order, adaptive for Mesh, Torus and Hypercube; geographic for Random_Geometric)")
+
+	sourceRoute = flag.String("source-route", "none", "source route `mode` for injected envelopes (none, strict, loose)")
+	pathCount   = flag.Uint("paths", 1, "least cost paths each source and destination's flows are pinned across by source routing")
+
+	groupCount  = flag.Uint("groups", 1, "multicast or anycast groups for the Multicast and Anycast modes")
+	memberCount = flag.Uint("members", 4, "members of each multicast group")
+
+	labelSwitching = flag.Bool("labels", false, "forward by label over paths set up by each envelope's ingress")
+
+	addressing = flag.Bool("address", false, "address envelopes by their destination's IPv4 host address, resolved by longest prefix match")
+	allocation = flag.String("ipam", "random", "address allocation `mode` (random, sequential, negotiated)")
+	supernet   = flag.String("supernet", "10.0.0.0/8", "CIDR `block` the sequential and negotiated allocation modes carve router blocks from")
+
+	relinkCount  = flag.Uint("relink", 0, "links to take down and to bring up at random while the first round's envelopes are in flight")
+	readdressing = flag.String("readdress", "none", "re-addressing `mode` once a router's links change (none, static, dynamic, dns)")
+	aliasHold    = flag.Duration("alias-hold", time.Second, "time a router renumbered under the dns mode keeps answering to its old address")
+
+	measureDisjoint = flag.Bool("redundancy", false, "count edge and node disjoint paths between every pair of routers")
+
+	symmetrise = flag.Bool("symmetrise", false, "repair one sided links, self loops and duplicate neighbours in the topology")
+
+	exportTopology = flag.String("export-topology", "", "write the topology to `file` (format by extension: .dot, .json)")
+	exportState    = flag.String("export-state", "", "write the routers' learned state after the test to `file` (format by extension: .dot, .json)")
 )
 
+// sourceRouting ... Source routes given to the injected envelopes
+var sourceRouting *sourceRoutes
+
 func main() {
 	flag.Parse()
 
-	if *size == 0 && (*topology == "Line" || *topology == "Ring" || *topology == "Star" || *topology == "Fully_Connected" ||
-		*topology == "Mesh" || *topology == "Torus") {
+	params := topology.Params{
+		Size:        *size,
+		Dimension:   *dimension,
+		Probability: *probability,
+		Degree:      *degree,
+		Radius:      *radius,
+		Seed:        *seed,
+		Connected:   *connected,
+	}
+	network, place, err := buildNetwork(*topologyName, params, *routing)
+	if err == topology.ErrNoRouters {
 		fmt.Fprintln(os.Stderr, "You have requested a topology with zero routers. Try increasing size (-s).")
 		os.Exit(1)
+	} else if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		flag.Usage()
+		os.Exit(1)
 	}
-
-	var template routers.Template
-	switch *topology {
-	case "Line", "Ring":
-		template = make(routers.Template, *size)
-		for i := routers.RouterId(0); uint(i) < *size; i++ {
-			template[i] = []routers.RouterId{i - 1, i + 1}
-		}
-		if *topology == "Line" {
-			template[0] = template[0][1:]
-			template[*size-1] = template[*size-1][:len(template[*size-1])-1]
-		} else {
-			template[0][0] = routers.RouterId(*size - 1)
-			template[*size-1][1] = 0
-		}
-	case "Star":
-		template = make(routers.Template, *size)
-		if *size > 0 {
-			template[0] = make([]routers.RouterId, *size-1)
-			for i := routers.RouterId(1); uint(i) < *size; i++ {
-				template[0][i-1] = i
-			}
-			for i := uint(1); i < *size; i++ {
-				template[i] = []routers.RouterId{0}
-			}
-		}
-	case "Fully_Connected":
-		template = make(routers.Template, *size)
-		for i := routers.RouterId(0); uint(i) < *size; i++ {
-			template[i] = make([]routers.RouterId, *size-1)
-			for j := routers.RouterId(0); uint(j) < *size-1; j++ {
-				if j < i {
-					template[i][j] = j
-				} else {
-					template[i][j] = j + 1
-				}
-			}
-		}
-	case "Mesh":
-		template = make(routers.Template, exp(*size, *dimension))
-		for i := routers.RouterId(0); int(i) < len(template); i++ {
-			temp := make(map[routers.RouterId]struct{})
-			for d := uint(0); d < *dimension; d++ {
-				if int(i)-(1<<d) >= 0 {
-					temp[i-(1<<d)] = struct{}{}
-				}
-				if int(i)+(1<<d) < len(template) {
-					temp[i+(1<<d)] = struct{}{}
-				}
-			}
-			template[i] = make([]routers.RouterId, len(temp))
-			j := 0
-			for n := range temp {
-				template[i][j] = n
-				j++
+	weighted := network.Weighted()
+	if *symmetrise {
+		// Route over the repaired template, and compute flows and utilisation from it too
+		weighted = weighted.Symmetrise()
+	}
+	template := weighted.Template
+	if *exportTopology != "" {
+		if err := writeExport(*exportTopology, func(f *os.File, format string) error {
+			if format == "json" {
+				return topology.WriteJSON(f, network)
 			}
+			return topology.WriteDOT(f, network)
+		}); err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to export topology to %s: %v\n", *exportTopology, err)
+			os.Exit(1)
 		}
-	default:
-		fmt.Fprintf(os.Stderr, "Unsupported topology %s\n", *topology)
-		flag.Usage()
-		os.Exit(1)
 	}
 
 	fmt.Println("+------------------------------")
-	fmt.Printf("| Network Type = %v\n", *topology)
+	fmt.Printf("| Network Type = %v\n", *topologyName)
 	fmt.Printf("| Size = %v\n", *size)
+	fmt.Printf("| Routers = %v\n", len(template))
 	fmt.Printf("| Dimension = %v\n", *dimension)
 	fmt.Printf("| Mode = %v\n", *mode)
-	fmt.Printf("| Dropouts = %v\n", *dropouts)
+	fmt.Printf("| Dropouts = %v\n", *dropoutCount)
+	fmt.Printf("| Relinks = %v\n", *relinkCount)
+	fmt.Printf("| Re-addressing = %v\n", *readdressing)
 	fmt.Printf("| Repeats = %v\n", *repeats)
+	fmt.Printf("| Rebuild = %v\n", *rebuild)
 	fmt.Printf("| Logging Level = %v\n", *logging)
+	fmt.Printf("| Seed = %v\n", *seed)
+	fmt.Printf("| Generator = %v\n", *generator)
+	fmt.Printf("| Multipath = %v\n", *multipath)
+	fmt.Printf("| Routing = %v\n", *routing)
+	fmt.Printf("| Source Route = %v\n", *sourceRoute)
+	fmt.Printf("| Label Switching = %v\n", *labelSwitching)
+	fmt.Printf("| Address by IPv4 = %v\n", *addressing)
+	fmt.Printf("| Address Allocation = %v\n", *allocation)
+	if *generator != "Burst" {
+		fmt.Printf("| Rate = %v/s\n", *rate)
+		fmt.Printf("| Duration = %v\n", *duration)
+	}
 	fmt.Println("+------------------------------")
 
-	in, out := routers.MakeRouters(template, *logging, *printConnections)
+	if *output != "" {
+		if _, err := outputFormat(*output); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+
+	if !isTrafficMode(*mode) {
+		fmt.Fprintf(os.Stderr, "Unsupported test mode %s\n", *mode)
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	if !isGenerator(*generator) {
+		fmt.Fprintf(os.Stderr, "Unsupported traffic generator %s\n", *generator)
+		flag.Usage()
+		os.Exit(1)
+	}
+	if isGroupMode(*mode) && *generator != "Burst" {
+		fmt.Fprintf(os.Stderr, "The %s mode requires the Burst generator.\n", *mode)
+		os.Exit(1)
+	}
+	if isGroupMode(*mode) && *addressing {
+		fmt.Fprintf(os.Stderr, "The %s mode can't address envelopes by IPv4.\n", *mode)
+		os.Exit(1)
+	}
+	block, err := routers.ParseIPv4(*supernet)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if *generator == "On_Off" && *onPeriod <= 0 {
+		fmt.Fprintln(os.Stderr, "The On_Off generator requires a positive on period (-on).")
+		os.Exit(1)
+	}
+	var matrix TrafficMatrix
+	if *matrixFile != "" {
+		if *generator == "Burst" {
+			fmt.Fprintln(os.Stderr, "A traffic matrix requires a timed generator (-g).")
+			os.Exit(1)
+		}
+		m, err := loadTrafficMatrix(*matrixFile, len(template))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		matrix = m
+	}
+
+	if *flowCount == 0 {
+		fmt.Fprintln(os.Stderr, "You have requested zero flow labels. Try increasing flows (-flows).")
+		os.Exit(1)
+	}
+
+	if *repeats == 0 {
+		fmt.Fprintln(os.Stderr, "You have requested zero repeats. Try increasing repeats (-r).")
+		os.Exit(1)
+	}
 
+	sourceRouting, err = newSourceRoutes(*sourceRoute, *pathCount, template, weighted.Costs)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	rng := rand.New(rand.NewSource(*seed))
+	failures, err := chooseDropouts(len(template), *dropoutCount, rng)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if err := failures.relink(template, *relinkCount, rng); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	members := membership{}
+	if *mode == "Multicast" || *mode == "Anycast" {
+		if members, err = chooseMembers(len(template), *groupCount, *memberCount, rng); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+
+	config := routers.Config{
+		LogLevel:         *logging,
+		PrintConnections: *printConnections,
+		Multipath:        *multipath,
+		FastReroute:      *fastReroute,
+		ConvergenceDelay: *convergenceDelay,
+		Routing:          *routing,
+		Grid:             place.grid,
+		Positions:        place.positions,
+		LabelSwitching:   *labelSwitching,
+		Allocation:       *allocation,
+		Supernet:         block,
+		Readdressing:     *readdressing,
+		AliasHold:        *aliasHold,
+		Seed:             *seed,
+	}
+	in, out, stop := makeRouters(weighted, config)
+	members.join(in)
 	time.Sleep(*settleTime)
+	if *addressing {
+		destAddresses = settledAddresses(in)
+	}
+	addresses := routerBlocks(in)
+	builds := uint(1)
+
+	durations := make([]float64, 0, *repeats)
+	hops := make([]float64, 0)
+	costs := make([]float64, 0)
+	records := make([]envelopeRecord, 0)
+	throughput := make([]float64, 0)
+	offered := make([]float64, 0)
+	lost := 0
+	for r := uint(0); r < *repeats; r++ {
+		if r > 0 && *rebuild {
+			// Tear the last network down before starting its replacement
+			stop()
+			in, out, stop = makeRouters(weighted, config)
+			members.join(in)
+			time.Sleep(*settleTime)
+			if *addressing {
+				destAddresses = settledAddresses(in)
+			}
+			addresses = routerBlocks(in)
+			builds++
+		}
+		// Routers drop out of each newly built network during its first round
+		fresh := r == 0 || *rebuild
+		var result roundResult
+		if isGroupMode(*mode) {
+			result = runGroupRound(template, in, out, r, members, failures, fresh)
+		} else if *generator == "Burst" {
+			result = runRound(template, in, out, r, rng, failures, fresh)
+		} else {
+			result = runGenerated(template, in, out, r, rng, matrix, failures, fresh)
+			throughput = append(throughput, float64(len(result.envelopes))/result.duration.Seconds())
+			offered = append(offered, float64(result.sent)/result.duration.Seconds())
+		}
+		if fresh && *relinkCount > 0 && *addressing {
+			// Look the addresses up again for the next round, routers may have been renumbered
+			destAddresses = settledAddresses(in)
+		}
+		lost += result.lost
+		durations = append(durations, float64(result.duration))
+		roundHops := make([]float64, len(result.envelopes))
+		for i, e := range result.envelopes {
+			roundHops[i] = float64(e.Hops)
+		}
+		hops = append(hops, roundHops...)
+		for _, e := range result.envelopes {
+			costs = append(costs, e.Cost)
+		}
+		records = append(records, result.envelopes...)
+		roundStats := summarise(roundHops)
+		log.Printf("| Repeat %v/%v completed in %v {Hops: min %v, max %v, avg %v}\n",
+			r+1,
+			*repeats,
+			result.duration,
+			roundStats.Min,
+			roundStats.Max,
+			roundStats.Mean)
+	}
+
+	states := routers.QueryState(in, time.Second)
+	utilisation := collectUtilisation(template, states)
+	utilisationStats := summariseUtilisation(utilisation)
+	forwarding := collectForwarding(states)
+	overlaps := overlappingBlocks(states)
+	fastReroutes := uint64(0)
+	for _, s := range states {
+		fastReroutes += s.FastReroutes
+	}
+	saved := 0
+	for _, e := range records {
+		if e.Rerouted {
+			saved++
+		}
+	}
+	if *exportState != "" {
+		if err := writeExport(*exportState, func(f *os.File, format string) error {
+			if format == "json" {
+				return routers.WriteStateJSON(f, states)
+			}
+			return routers.WriteStateDOT(f, template, states)
+		}); err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to export router state to %s: %v\n", *exportState, err)
+			os.Exit(1)
+		}
+	}
+
+	timing := summarise(durations)
+	hopStats := summarise(hops)
+	costStats := summarise(costs)
+	latency := latencyBreakdown(records)
+	fmt.Println()
+	log.Println("+----------------------------------------------")
+	log.Printf("| Test completed %v repeats\n", *repeats)
+	if *generator != "Burst" {
+		tp := summarise(throughput)
+		log.Println("| -> Throughput")
+		log.Printf("|    Offered: %.1f/s, Delivered: %.1f/s\n", summarise(offered).Mean, tp.Mean)
+		log.Printf("|    Std Dev: %.1f/s, Median: %.1f/s\n", tp.StdDev, tp.Median)
+		log.Printf("|    Lost: %v\n", lost)
+	}
+	if *dropoutCount > 0 {
+		log.Println("| -> Dropouts")
+		log.Printf("|    Routers: %v\n", failures.Routers)
+		log.Printf("|    Lost: %v, Fast Rerouted: %v, Saved: %v\n", lost, fastReroutes, saved)
+	}
+	if *relinkCount > 0 {
+		log.Println("| -> Relinks")
+		log.Printf("|    Taken Down: %v\n", failures.Cut)
+		log.Printf("|    Brought Up: %v\n", failures.Added)
+		log.Printf("|    Lost: %v, Routers Renumbered: %v\n", lost, renumbered(addresses, states))
+	}
+	log.Println("| -> Completion Time")
+	log.Printf("|    Mean: %v, Std Dev: %v\n", time.Duration(timing.Mean), time.Duration(timing.StdDev))
+	log.Printf("|    Median: %v, P95: %v, P99: %v\n", time.Duration(timing.Median), time.Duration(timing.P95), time.Duration(timing.P99))
+	log.Println("| -> Hops")
+	log.Printf("|    Minimum: %v, Maximum: %v\n", hopStats.Min, hopStats.Max)
+	log.Printf("|    Mean: %.3f, Std Dev: %.3f\n", hopStats.Mean, hopStats.StdDev)
+	log.Printf("|    Median: %v, P95: %v, P99: %v\n", hopStats.Median, hopStats.P95, hopStats.P99)
+	log.Println("| -> Path Cost")
+	log.Printf("|    Minimum: %v, Maximum: %v\n", costStats.Min, costStats.Max)
+	log.Printf("|    Mean: %.3f, Std Dev: %.3f\n", costStats.Mean, costStats.StdDev)
+	log.Printf("|    Median: %v, P95: %v, P99: %v\n", costStats.Median, costStats.P95, costStats.P99)
+	printLatency(latency, *logging == "verbose")
+	printUtilisation(utilisation, utilisationStats, *logging == "verbose")
+	printForwarding(forwarding)
+	log.Println("| -> Addressing")
+	log.Printf("|    Blocks: %v, Overlapping pairs: %v\n", len(states), overlaps)
+	if *mode == "Anycast" {
+		log.Println("| -> Anycast")
+		log.Printf("|    Delivered to a nearest member: %v of %v\n", anycastNearest(template, weighted.Costs, members, records), len(records))
+	}
+	var topologyRedundancy *redundancy
+	if *measureDisjoint {
+		r := measureRedundancy(template, weighted.Costs)
+		topologyRedundancy = &r
+		printRedundancy(r)
+	}
+	log.Println("+----------------------------------------------")
+
+	if *output != "" {
+		doc := resultDocument{
+			Topology:    *topologyName,
+			Size:        *size,
+			Dimension:   *dimension,
+			Mode:        *mode,
+			Seed:        *seed,
+			Repeats:     *repeats,
+			Generator:   *generator,
+			Multipath:   *multipath,
+			Routing:     *routing,
+			SourceRoute: *sourceRoute,
+			Labels:      *labelSwitching,
+			Addressing:  *addressing,
+			Allocation:  *allocation,
+			Overlaps:    overlaps,
+			Routers:     len(template),
+			Envelopes:   records,
+			Utilisation: utilisation,
+			Redundancy:  topologyRedundancy,
+			Aggregates: aggregates{
+				CompletionTime:  timing,
+				Hops:            hopStats,
+				Cost:            costStats,
+				Latency:         latency,
+				Throughput:      summarise(throughput),
+				Offered:         summarise(offered),
+				Lost:            lost,
+				LinkUtilisation: utilisationStats,
+				FastReroutes:    fastReroutes,
+				Saved:           saved,
+				Forwarding:      forwarding,
+			},
+			Dropouts: failures.Routers,
+			Relinks:  relinks{Cut: failures.Cut, Added: failures.Added, Renumbered: renumbered(addresses, states)},
+			Convergence: convergence{
+				SettleTime:    *settleTime,
+				NetworkBuilds: builds,
+			},
+		}
+		if err := writeResults(*output, doc); err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to write results to %s: %v\n", *output, err)
+			os.Exit(1)
+		}
+		log.Printf("Results written to %s\n", *output)
+		if format, _ := outputFormat(*output); format == "csv" {
+			log.Printf("Summary written to %s\n", summaryPath(*output))
+		}
+	}
+}
+
+// roundResult ... Measurements collected from a single repeat of the test mode
+type roundResult struct {
+	duration  time.Duration
+	envelopes []envelopeRecord
+	sent      int
+	lost      int
+}
+
+// runRound ... Send one round of envelopes through the network as per the test mode and wait for all to arrive,
+// or until none has arrived for the drain time. With {fail} set the dropouts fail once the envelopes are sent
+func runRound(template routers.Template, in []chan<- interface{}, out <-chan routers.Envelope, repeat uint, rng *rand.Rand, failures dropouts, fail bool) roundResult {
+	flows, err := buildFlows(*mode, template, rng)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	flows = failures.alive(flows)
+
 	start := time.Now()
+	done := make(chan struct{})
+	defer close(done)
 
-	msgs := make(map[uint]struct{})
-
-	for i := routers.RouterId(0); int(i) < len(template); i++ {
-		msgs[uint(i)] = struct{}{}
-		go func(j routers.RouterId) {
-			switch *mode {
-			case "One_To_All":
-				in[0] <- routers.Envelope{
-					Dest:    j,
-					Hops:    0,
-					Message: uint(j),
-				}
-			case "All_To_One":
-				in[j] <- routers.Envelope{
-					Dest:    0,
-					Hops:    0,
-					Message: uint(j),
-				}
-			default:
-				fmt.Fprintf(os.Stderr, "Unsupported test mode %s\n", *mode)
-				flag.Usage()
-				os.Exit(1)
+	msgs := make(map[msgKey]envelopeRecord, len(flows))
+	seqs := make(map[flow]uint)
+	for _, f := range flows {
+		key := msgKey{repeat, f.Source, f.Dest, seqs[f]}
+		seqs[f]++
+		msgs[key] = envelopeRecord{Repeat: repeat, Source: f.Source, Dest: f.Dest}
+	}
+	for key := range msgs {
+		inject(in, key.envelope(), done)
+	}
+	if fail {
+		failures.fail(in)
+	}
+	return awaitDelivery(out, msgs, start)
+}
+
+// inject ... Place the envelope on its source router's input without holding up the caller, giving up once {done}
+// is closed so that a sender blocked on a router that no longer reads doesn't outlive the round
+func inject(in []chan<- interface{}, envelope routers.Envelope, done <-chan struct{}) {
+	go func() {
+		select {
+		case in[envelope.Source] <- envelope:
+		case <-done:
+		}
+	}()
+}
+
+// awaitDelivery ... Record each expected envelope as it is delivered, until all have arrived or none has for the
+// drain time. Copies of broadcast and multicast envelopes are matched by the router that delivered them, anycast
+// envelopes record the member that did
+func awaitDelivery(out <-chan routers.Envelope, msgs map[msgKey]envelopeRecord, start time.Time) roundResult {
+	result := roundResult{envelopes: make([]envelopeRecord, 0, len(msgs))}
+	for len(msgs) > 0 {
+		var envelope routers.Envelope
+		select {
+		case envelope = <-out:
+		case <-time.After(*drainTimeout):
+			result.lost = len(msgs)
+			result.duration = time.Since(start) - *drainTimeout
+			return result
+		}
+		if i, ok := envelope.Message.(msgKey); ok {
+			if envelope.Broadcast || envelope.Group != 0 {
+				i.Dest = envelope.Dest
+			} else if envelope.Anycast == 0 && envelope.Dest != i.Dest {
+				log.Printf("Envelope for [%v] addressed to %v was delivered to [%v]", i.Dest, envelope.DestAddress, envelope.Dest)
 			}
-		}(i)
-	}
-	totalHops := uint(0)
-	minHops := *size
-	maxHops := uint(0)
-	numMessages := len(msgs)
-	for {
-		envelope := <-out
-		if i, ok := envelope.Message.(uint); ok {
-			if _, ok := msgs[i]; ok {
-				totalHops += envelope.Hops
-				if envelope.Hops < minHops {
-					minHops = envelope.Hops
-				}
-				if envelope.Hops > maxHops {
-					maxHops = envelope.Hops
-				}
+			if record, ok := msgs[i]; ok {
+				record.Dest = envelope.Dest
+				record.Hops = envelope.Hops
+				record.Cost = envelope.Cost
+				record.Latency = envelope.Delivered.Sub(envelope.Injected)
+				record.Rerouted = envelope.Rerouted
+				result.envelopes = append(result.envelopes, record)
 				delete(msgs, i)
-				if len(msgs) == 0 {
-					break
-				}
 			} else {
 				log.Printf("Unexpected message value %v! Make sure you aren't duplicating Envelopes.", i)
 			}
@@ -164,17 +526,74 @@ func main() {
 			log.Printf("Unexpected message body %g! Make sure you aren't editing Envelopes.", envelope.Message)
 		}
 	}
-	fmt.Println()
-	log.Println("+----------------------------------------------")
-	log.Printf("| Test completed in %v\n", time.Since(start))
-	log.Printf("| -> Minimum Hops: %v\n", minHops)
-	log.Printf("| -> Maximum Hops: %v\n", maxHops)
-	log.Printf("| -> Average Hops: %v\n", float64(totalHops)/float64(numMessages))
-	log.Println("+----------------------------------------------")
+	result.duration = time.Since(start)
+	return result
 }
 
-func exp(x uint, y uint) uint {
-	z := new(big.Int).Exp(new(big.Int).SetUint64(uint64(*size)), new(big.Int).SetUint64(uint64(*dimension)), nil)
+// makeRouters ... Start the routers, exiting with the weighted template's problems if it is invalid. Calling the
+// returned function stops them
+func makeRouters(weighted routers.WeightedTemplate, config routers.Config) ([]chan<- interface{}, <-chan routers.Envelope, func()) {
+	done := make(chan struct{})
+	config.Done = done
+	in, out, err := routers.MakeWeightedRouters(weighted, config)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	return in, out, func() { close(done) }
+}
+
+// placement ... Where the generated routers are, for the routing modes that route by location
+type placement struct {
+	grid      *routers.Grid
+	positions [][2]float64
+}
+
+// buildNetwork ... Load the topology from a file when given as file:path, otherwise generate it. Routing modes
+// other than table also need the placement of the generated routers
+func buildNetwork(name string, params topology.Params, routing string) (*topology.Network, placement, error) {
+	located := routing == "dimension_order" || routing == "adaptive" || routing == "geographic"
+	if strings.HasPrefix(name, "file:") {
+		if located {
+			return nil, placement{}, fmt.Errorf("%s routing requires a generated topology", routing)
+		}
+		network, err := topology.LoadFile(strings.TrimPrefix(name, "file:"))
+		return network, placement{}, err
+	}
+	checkRouterCount(name, params)
+	switch {
+	case routing == "geographic":
+		if name != "Random_Geometric" {
+			return nil, placement{}, fmt.Errorf("geographic routing requires a Random_Geometric topology")
+		}
+		template, positions, err := topology.RandomGeometric(params.Size, params.Radius,
+			topology.RandomOptions{Seed: params.Seed, Connected: params.Connected})
+		if err != nil {
+			return nil, placement{}, err
+		}
+		return &topology.Network{Template: template}, placement{positions: positions}, nil
+	case located:
+		template, grid, err := topology.GenerateGrid(name, params)
+		if err != nil {
+			return nil, placement{}, err
+		}
+		return &topology.Network{Template: template}, placement{grid: grid}, nil
+	}
+	template, err := topology.Generate(name, params)
+	if err != nil {
+		return nil, placement{}, err
+	}
+	return &topology.Network{Template: template}, placement{}, nil
+}
+
+// checkRouterCount ... Refuse to generate very large networks unless forced
+func checkRouterCount(name string, params topology.Params) {
+	z, err := topology.Count(name, params)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unsupported topology %s\n", name)
+		flag.Usage()
+		os.Exit(1)
+	}
 	if z.Cmp(big.NewInt(1024)) > 0 && !*force {
 		fmt.Fprintf(os.Stderr, "Your chosen configuration would generate a very large number of routers.\n"+
 			"Try setting dimension (-d) smaller. (Would generate %v routers.)\n"+
@@ -185,5 +604,4 @@ func exp(x uint, y uint) uint {
 		fmt.Fprintln(os.Stderr, "OK, but seriously though, this would generate more than 2^64 routers. Aborting.")
 		os.Exit(1)
 	}
-	return uint(z.Uint64())
 }
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	"routers"
+)
+
+// isGroupMode ... Check if the traffic mode delivers each envelope to many routers
+func isGroupMode(mode string) bool {
+	return mode == "Broadcast" || mode == "Multicast" || mode == "Anycast"
+}
+
+// membership ... Members of each multicast group, numbered from 1
+type membership map[routers.GroupId][]routers.RouterId
+
+// chooseMembers ... Pick {members} distinct routers at random for each of {groups} groups
+func chooseMembers(count int, groups uint, members uint, rng *rand.Rand) (membership, error) {
+	if groups > 0 && (members == 0 || int(members) > count) {
+		return nil, fmt.Errorf("cannot choose %v members from %v routers. Try changing members (-members)", members, count)
+	}
+	m := make(membership, groups)
+	for g := uint(1); g <= groups; g++ {
+		ids := make([]routers.RouterId, members)
+		for i, id := range rng.Perm(count)[:members] {
+			ids[i] = routers.RouterId(id)
+		}
+		m[routers.GroupId(g)] = ids
+	}
+	return m, nil
+}
+
+// join ... Tell every member to join its groups
+func (m membership) join(in []chan<- interface{}) {
+	for group, ids := range m {
+		for _, id := range ids {
+			in[id] <- routers.Join{Group: group}
+		}
+	}
+}
+
+// runGroupRound ... Send an envelope from every router, to every router or to each multicast or anycast group, and
+// wait for each receiver's copy to arrive, or until none has arrived for the drain time. With {fail} set the
+// dropouts fail once the envelopes are sent
+func runGroupRound(template routers.Template, in []chan<- interface{}, out <-chan routers.Envelope, repeat uint, members membership, failures dropouts, fail bool) roundResult {
+	start := time.Now()
+	msgs := make(map[msgKey]envelopeRecord)
+	envelopes := make([]routers.Envelope, 0)
+	for i := range template {
+		source := routers.RouterId(i)
+		if failures.dead[source] {
+			continue
+		}
+		// The key's Seq names the group, each copy's key takes the receiver as Dest
+		receivers := make(map[routers.GroupId][]routers.RouterId)
+		if *mode == "Broadcast" {
+			for j := range template {
+				receivers[0] = append(receivers[0], routers.RouterId(j))
+			}
+		} else {
+			for group, ids := range members {
+				receivers[group] = ids
+			}
+		}
+		for group, ids := range receivers {
+			k := msgKey{repeat, source, source, uint(group)}
+			if *mode == "Anycast" {
+				// Any one live member will do, the record learns which on delivery
+				for _, id := range ids {
+					if !failures.dead[id] {
+						msgs[k] = envelopeRecord{Repeat: repeat, Source: source, Group: group}
+						break
+					}
+				}
+			} else {
+				for _, id := range ids {
+					if !failures.dead[id] {
+						msgs[msgKey{repeat, source, id, uint(group)}] = envelopeRecord{Repeat: repeat, Source: source, Dest: id, Group: group}
+					}
+				}
+			}
+			envelope := k.envelope()
+			switch *mode {
+			case "Broadcast":
+				envelope.Broadcast = true
+			case "Multicast":
+				envelope.Group = group
+			default:
+				envelope.Anycast = group
+			}
+			envelopes = append(envelopes, envelope)
+		}
+	}
+	done := make(chan struct{})
+	defer close(done)
+	for _, e := range envelopes {
+		inject(in, e, done)
+	}
+	if fail {
+		failures.fail(in)
+	}
+	return awaitDelivery(out, msgs, start)
+}
+
+// anycastNearest ... Count the anycast envelopes delivered to one of the members nearest their source in the topology
+func anycastNearest(template routers.Template, costs routers.Costs, members membership, records []envelopeRecord) int {
+	table := routers.TableFromTemplate(template, costs)
+	nearest := 0
+	for _, r := range records {
+		best, delivered := -1.0, -1.0
+		for _, id := range members[r.Group] {
+			_, cost := routers.EqualCostNextHops(table, r.Source, id)
+			if best < 0 || cost < best {
+				best = cost
+			}
+			if id == r.Dest {
+				delivered = cost
+			}
+		}
+		if delivered >= 0 && delivered <= best {
+			nearest++
+		}
+	}
+	return nearest
+}
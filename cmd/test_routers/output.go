@@ -0,0 +1,239 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"routers"
+)
+
+// envelopeRecord ... Measurements for a single delivered envelope
+type envelopeRecord struct {
+	Repeat   uint             `json:"repeat"`
+	Source   routers.RouterId `json:"source"`
+	Dest     routers.RouterId `json:"destination"`
+	Hops     uint             `json:"hops"`
+	Cost     float64          `json:"cost"`
+	Latency  time.Duration    `json:"latency_ns"`
+	Rerouted bool             `json:"rerouted"`
+	Group    routers.GroupId  `json:"group,omitempty"` // Multicast or anycast group the envelope was sent to
+}
+
+// aggregates ... Statistics across all repeats of the test
+type aggregates struct {
+	CompletionTime summary      `json:"completion_time_ns"`
+	Hops           summary      `json:"hops"`
+	Cost           summary      `json:"cost"`
+	Latency        latencyStats `json:"latency"`
+	Throughput     summary      `json:"throughput_per_second"`
+	Offered        summary      `json:"offered_per_second"`
+	Lost           int          `json:"lost"`
+	// Envelopes forwarded per directed link of the final network
+	LinkUtilisation summary `json:"link_utilisation"`
+	// Envelopes sent to a loop free alternate after their primary next hops failed, and those then delivered
+	FastReroutes uint64 `json:"fast_reroutes"`
+	Saved        int    `json:"saved_by_fast_reroute"`
+	// Forwarding decisions by label and by routing table lookup
+	Forwarding forwardingCost `json:"forwarding"`
+}
+
+// convergence ... Network construction and settling statistics
+type convergence struct {
+	SettleTime    time.Duration `json:"settle_time_ns"`
+	NetworkBuilds uint          `json:"network_builds"`
+}
+
+// resultDocument ... Structured record of a complete test run
+type resultDocument struct {
+	Topology    string             `json:"topology"`
+	Size        uint               `json:"size"`
+	Dimension   uint               `json:"dimension"`
+	Mode        string             `json:"mode"`
+	Seed        int64              `json:"seed"`
+	Repeats     uint               `json:"repeats"`
+	Generator   string             `json:"generator"`
+	Multipath   string             `json:"multipath"`
+	Routing     string             `json:"routing"`
+	SourceRoute string             `json:"source_route"`
+	Labels      bool               `json:"label_switching"`
+	Addressing  bool               `json:"address_by_ipv4"`
+	Allocation  string             `json:"allocation"`
+	Overlaps    int                `json:"overlapping_blocks"`
+	Routers     int                `json:"routers"`
+	Envelopes   []envelopeRecord   `json:"envelopes"`
+	Utilisation []linkUtilisation  `json:"link_utilisation"`
+	Redundancy  *redundancy        `json:"redundancy,omitempty"`
+	Dropouts    []routers.RouterId `json:"dropouts"`
+	Relinks     relinks            `json:"relinks"`
+	Aggregates  aggregates         `json:"aggregates"`
+	Convergence convergence        `json:"convergence"`
+}
+
+// relinks ... Links taken down and brought up during the test and the routers renumbered as a result
+type relinks struct {
+	Cut        []routers.Link `json:"taken_down"`
+	Added      []routers.Link `json:"brought_up"`
+	Renumbered int            `json:"renumbered"`
+}
+
+// outputFormat ... Determine the results format from the extension of the output file
+func outputFormat(path string) (string, error) {
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json", ".csv":
+		return ext[1:], nil
+	default:
+		return "", fmt.Errorf("unsupported output format %q (expected .json or .csv)", ext)
+	}
+}
+
+// writeResults ... Write the result document to {path} as JSON or CSV. As CSV the envelopes go to {path} and the
+// aggregate and convergence figures to its summaryPath
+func writeResults(path string, doc resultDocument) error {
+	format, err := outputFormat(path)
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if format == "json" {
+		encoder := json.NewEncoder(f)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(doc)
+	}
+	if err := writeCSV(f, doc); err != nil {
+		return err
+	}
+	s, err := os.Create(summaryPath(path))
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	return writeSummaryCSV(s, doc)
+}
+
+// summaryPath ... File beside the CSV results at {path} that their summary is written to
+func summaryPath(path string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path)) + "_summary" + filepath.Ext(path)
+}
+
+// writeExport ... Create {path} and write to it in the DOT or JSON format given by its extension
+func writeExport(path string, write func(f *os.File, format string) error) error {
+	format := strings.ToLower(strings.TrimPrefix(filepath.Ext(path), "."))
+	if format != "dot" && format != "json" {
+		return fmt.Errorf("unsupported export format %q (expected .dot or .json)", filepath.Ext(path))
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f, format); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// writeCSV ... Write one row per delivered envelope, repeating the run parameters on each row
+func writeCSV(f *os.File, doc resultDocument) error {
+	w := csv.NewWriter(f)
+	header := []string{"topology", "size", "dimension", "mode", "seed", "repeat", "source", "destination", "hops", "cost", "latency_ns", "rerouted", "group"}
+	if err := w.Write(header); err != nil {
+		return err
+	}
+	for _, e := range doc.Envelopes {
+		row := []string{
+			doc.Topology,
+			strconv.FormatUint(uint64(doc.Size), 10),
+			strconv.FormatUint(uint64(doc.Dimension), 10),
+			doc.Mode,
+			strconv.FormatInt(doc.Seed, 10),
+			strconv.FormatUint(uint64(e.Repeat), 10),
+			strconv.FormatUint(uint64(e.Source), 10),
+			strconv.FormatUint(uint64(e.Dest), 10),
+			strconv.FormatUint(uint64(e.Hops), 10),
+			strconv.FormatFloat(e.Cost, 'g', -1, 64),
+			strconv.FormatInt(int64(e.Latency), 10),
+			strconv.FormatBool(e.Rerouted),
+			strconv.FormatUint(uint64(e.Group), 10),
+		}
+		if err := w.Write(row); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// summaryRows ... A metric,value row for each statistic of {s}, named {name}.statistic
+func summaryRows(name string, s summary) [][2]string {
+	return [][2]string{
+		{name + ".count", strconv.Itoa(s.Count)},
+		{name + ".min", strconv.FormatFloat(s.Min, 'g', -1, 64)},
+		{name + ".max", strconv.FormatFloat(s.Max, 'g', -1, 64)},
+		{name + ".mean", strconv.FormatFloat(s.Mean, 'g', -1, 64)},
+		{name + ".std_dev", strconv.FormatFloat(s.StdDev, 'g', -1, 64)},
+		{name + ".median", strconv.FormatFloat(s.Median, 'g', -1, 64)},
+		{name + ".p95", strconv.FormatFloat(s.P95, 'g', -1, 64)},
+		{name + ".p99", strconv.FormatFloat(s.P99, 'g', -1, 64)},
+	}
+}
+
+// writeSummaryCSV ... Write one row per aggregate and convergence figure, named as in the JSON results and
+// repeating the run parameters on each row
+func writeSummaryCSV(f *os.File, doc resultDocument) error {
+	a := doc.Aggregates
+	metrics := make([][2]string, 0)
+	metrics = append(metrics, summaryRows("completion_time_ns", a.CompletionTime)...)
+	metrics = append(metrics, summaryRows("hops", a.Hops)...)
+	metrics = append(metrics, summaryRows("cost", a.Cost)...)
+	metrics = append(metrics, summaryRows("latency.overall_ns", a.Latency.Overall)...)
+	metrics = append(metrics, summaryRows("throughput_per_second", a.Throughput)...)
+	metrics = append(metrics, summaryRows("offered_per_second", a.Offered)...)
+	metrics = append(metrics, summaryRows("link_utilisation", a.LinkUtilisation)...)
+	metrics = append(metrics, [][2]string{
+		{"lost", strconv.Itoa(a.Lost)},
+		{"fast_reroutes", strconv.FormatUint(a.FastReroutes, 10)},
+		{"saved_by_fast_reroute", strconv.Itoa(a.Saved)},
+		{"forwarding.label_switched", strconv.FormatUint(a.Forwarding.LabelSwitched, 10)},
+		{"forwarding.routed", strconv.FormatUint(a.Forwarding.Routed, 10)},
+		{"forwarding.mean_label_time_ns", strconv.FormatInt(int64(a.Forwarding.LabelTime), 10)},
+		{"forwarding.mean_route_time_ns", strconv.FormatInt(int64(a.Forwarding.RouteTime), 10)},
+		{"forwarding.labels", strconv.Itoa(a.Forwarding.Labels)},
+		{"forwarding.time_saved_ns", strconv.FormatInt(int64(a.Forwarding.Saved), 10)},
+		{"overlapping_blocks", strconv.Itoa(doc.Overlaps)},
+		{"relinks.renumbered", strconv.Itoa(doc.Relinks.Renumbered)},
+		{"convergence.settle_time_ns", strconv.FormatInt(int64(doc.Convergence.SettleTime), 10)},
+		{"convergence.network_builds", strconv.FormatUint(uint64(doc.Convergence.NetworkBuilds), 10)},
+	}...)
+
+	w := csv.NewWriter(f)
+	if err := w.Write([]string{"topology", "size", "dimension", "mode", "seed", "metric", "value"}); err != nil {
+		return err
+	}
+	for _, m := range metrics {
+		row := []string{
+			doc.Topology,
+			strconv.FormatUint(uint64(doc.Size), 10),
+			strconv.FormatUint(uint64(doc.Dimension), 10),
+			doc.Mode,
+			strconv.FormatInt(doc.Seed, 10),
+			m[0],
+			m[1],
+		}
+		if err := w.Write(row); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"log"
+
+	"routers"
+)
+
+// redundancy ... Number of disjoint paths between every pair of routers in the topology
+type redundancy struct {
+	EdgeDisjoint summary `json:"edge_disjoint_paths"`
+	NodeDisjoint summary `json:"node_disjoint_paths"`
+}
+
+// measureRedundancy ... Count edge and node disjoint paths between every unordered pair of routers. The minimums
+// are the edge and node connectivity of the topology
+func measureRedundancy(template routers.Template, costs routers.Costs) redundancy {
+	table := routers.TableFromTemplate(template, costs)
+	edges := make([]float64, 0)
+	nodes := make([]float64, 0)
+	for i := range template {
+		for j := i + 1; j < len(template); j++ {
+			a, b := routers.RouterId(i), routers.RouterId(j)
+			edges = append(edges, float64(len(routers.EdgeDisjointPaths(table, a, b))))
+			nodes = append(nodes, float64(len(routers.NodeDisjointPaths(table, a, b))))
+		}
+	}
+	return redundancy{EdgeDisjoint: summarise(edges), NodeDisjoint: summarise(nodes)}
+}
+
+// printRedundancy ... Log the disjoint path counts across router pairs
+func printRedundancy(r redundancy) {
+	log.Println("| -> Redundancy")
+	log.Printf("|    Edge Disjoint Paths: min %v, mean %.3f, max %v\n", r.EdgeDisjoint.Min, r.EdgeDisjoint.Mean, r.EdgeDisjoint.Max)
+	log.Printf("|    Node Disjoint Paths: min %v, mean %.3f, max %v\n", r.NodeDisjoint.Min, r.NodeDisjoint.Mean, r.NodeDisjoint.Max)
+}
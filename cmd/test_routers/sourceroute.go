@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+
+	"routers"
+)
+
+// sourceRouteModes ... Supported values of the source route (-source-route) flag
+var sourceRouteModes = []string{"none", "strict", "loose"}
+
+// sourceRoutes ... Source routes for the injected envelopes, pinning each flow to one of the least cost paths
+// between its source and destination
+type sourceRoutes struct {
+	mode  string
+	k     int
+	table *routers.DVRTable
+	lock  sync.Mutex
+	paths map[flow][]routers.Route
+}
+
+// newSourceRoutes ... Source routes over the {k} least cost paths of the template
+func newSourceRoutes(mode string, k uint, template routers.Template, costs routers.Costs) (*sourceRoutes, error) {
+	supported := false
+	for _, m := range sourceRouteModes {
+		supported = supported || m == mode
+	}
+	if !supported {
+		return nil, fmt.Errorf("unsupported source route mode %s (expected none, strict or loose)", mode)
+	}
+	if k == 0 {
+		return nil, fmt.Errorf("you have requested source routes over zero paths. Try increasing paths (-paths)")
+	}
+	return &sourceRoutes{
+		mode:  mode,
+		k:     int(k),
+		table: routers.TableFromTemplate(template, costs),
+		paths: make(map[flow][]routers.Route),
+	}, nil
+}
+
+// route ... Segments of the source route for the envelope and whether they are strict. The path is chosen by flow
+// label, a strict route listing every router between the two ends and a loose one only the path's middle router
+func (s *sourceRoutes) route(k msgKey) ([]routers.RouterId, bool) {
+	if s == nil || s.mode == "none" || k.Source == k.Dest {
+		return nil, false
+	}
+	f := flow{k.Source, k.Dest}
+	s.lock.Lock()
+	paths, ok := s.paths[f]
+	if !ok {
+		paths = routers.KShortestPaths(s.table, k.Source, k.Dest, s.k)
+		s.paths[f] = paths
+	}
+	s.lock.Unlock()
+	if len(paths) == 0 {
+		return nil, false
+	}
+	path := paths[int(k.flowLabel())%len(paths)].Path
+	between := path[1 : len(path)-1]
+	if s.mode == "strict" {
+		return append([]routers.RouterId(nil), between...), true
+	}
+	if len(between) == 0 {
+		return nil, false
+	}
+	return []routers.RouterId{between[len(between)/2]}, false
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"math"
+	"sort"
+)
+
+// summary ... Descriptive statistics over a set of samples
+type summary struct {
+	Count  int     `json:"count"`
+	Min    float64 `json:"min"`
+	Max    float64 `json:"max"`
+	Mean   float64 `json:"mean"`
+	StdDev float64 `json:"std_dev"`
+	Median float64 `json:"median"`
+	P95    float64 `json:"p95"`
+	P99    float64 `json:"p99"`
+}
+
+// summarise ... Calculate the descriptive statistics of the given samples
+func summarise(samples []float64) summary {
+	s := summary{Count: len(samples)}
+	if len(samples) == 0 {
+		return s
+	}
+	sorted := make([]float64, len(samples))
+	copy(sorted, samples)
+	sort.Float64s(sorted)
+
+	total := 0.0
+	for _, v := range sorted {
+		total += v
+	}
+	s.Mean = total / float64(len(sorted))
+	variance := 0.0
+	for _, v := range sorted {
+		variance += (v - s.Mean) * (v - s.Mean)
+	}
+	s.StdDev = math.Sqrt(variance / float64(len(sorted)))
+	s.Min = sorted[0]
+	s.Max = sorted[len(sorted)-1]
+	s.Median = percentile(sorted, 50)
+	s.P95 = percentile(sorted, 95)
+	s.P99 = percentile(sorted, 99)
+	return s
+}
+
+// percentile ... Linearly interpolated percentile {p} (0-100) of an ascending sorted slice
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := (p / 100) * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	if lower == upper {
+		return sorted[lower]
+	}
+	return sorted[lower] + (rank-float64(lower))*(sorted[upper]-sorted[lower])
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPercentile(t *testing.T) {
+	tests := []struct {
+		name   string
+		sorted []float64
+		p      float64
+		want   float64
+	}{
+		{"empty", nil, 50, 0},
+		{"single", []float64{7}, 95, 7},
+		{"minimum", []float64{1, 2, 3, 4}, 0, 1},
+		{"maximum", []float64{1, 2, 3, 4}, 100, 4},
+		{"exact rank", []float64{1, 2, 3, 4, 5}, 50, 3},
+		{"interpolated median", []float64{1, 2, 3, 4}, 50, 2.5},
+		{"interpolated p95", []float64{0, 10, 20, 30, 40}, 95, 38},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := percentile(tt.sorted, tt.p); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("percentile(%v, %v) = %v, want %v", tt.sorted, tt.p, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSummarise(t *testing.T) {
+	tests := []struct {
+		name    string
+		samples []float64
+		want    summary
+	}{
+		{"empty", nil, summary{}},
+		{"single", []float64{3}, summary{Count: 1, Min: 3, Max: 3, Mean: 3, Median: 3, P95: 3, P99: 3}},
+		{
+			"unsorted",
+			[]float64{4, 2, 8, 6},
+			summary{Count: 4, Min: 2, Max: 8, Mean: 5, StdDev: math.Sqrt(5), Median: 5, P95: 7.7, P99: 7.94},
+		},
+		{
+			"constant",
+			[]float64{1, 1, 1},
+			summary{Count: 3, Min: 1, Max: 1, Mean: 1, Median: 1, P95: 1, P99: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := summarise(tt.samples)
+			fields := []struct {
+				name      string
+				got, want float64
+			}{
+				{"count", float64(got.Count), float64(tt.want.Count)},
+				{"min", got.Min, tt.want.Min},
+				{"max", got.Max, tt.want.Max},
+				{"mean", got.Mean, tt.want.Mean},
+				{"std dev", got.StdDev, tt.want.StdDev},
+				{"median", got.Median, tt.want.Median},
+				{"p95", got.P95, tt.want.P95},
+				{"p99", got.P99, tt.want.P99},
+			}
+			for _, f := range fields {
+				if math.Abs(f.got-f.want) > 1e-9 {
+					t.Errorf("summarise(%v) %v = %v, want %v", tt.samples, f.name, f.got, f.want)
+				}
+			}
+		})
+	}
+}
+
+func TestSummariseKeepsSamples(t *testing.T) {
+	samples := []float64{3, 1, 2}
+	summarise(samples)
+	if samples[0] != 3 || samples[1] != 1 || samples[2] != 2 {
+		t.Errorf("summarise reordered its samples to %v", samples)
+	}
+}
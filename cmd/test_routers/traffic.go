@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"math/bits"
+	"math/rand"
+
+	"routers"
+)
+
+// msgKey ... Unique identity of a test envelope within a round
+type msgKey struct {
+	Round  uint
+	Source routers.RouterId
+	Dest   routers.RouterId
+	Seq    uint
+}
+
+// flowLabel ... Flow label of the envelope, cycling through the configured number of flows per source and destination
+func (k msgKey) flowLabel() uint32 {
+	return uint32(k.Seq % *flowCount)
+}
+
+// envelope ... The test envelope for the key, source routed as configured
+func (k msgKey) envelope() routers.Envelope {
+	segments, strict := sourceRouting.route(k)
+	envelope := routers.Envelope{
+		Source:    k.Source,
+		Dest:      k.Dest,
+		FlowLabel: k.flowLabel(),
+		Hops:      0,
+		Message:   k,
+		Segments:  segments,
+		Strict:    strict,
+	}
+	if destAddresses != nil {
+		envelope.DestAddress = destAddresses[k.Dest]
+	}
+	return envelope
+}
+
+// flow ... A single envelope to be sent from Source to Dest
+type flow struct {
+	Source routers.RouterId
+	Dest   routers.RouterId
+}
+
+// trafficModes ... Supported values of the mode (-m) flag
+var trafficModes = []string{
+	"One_To_All",
+	"All_To_One",
+	"All_To_All",
+	"Random_Pairs",
+	"Permutation",
+	"Bit_Reversal",
+	"Transpose",
+	"Nearest_Neighbour",
+	"Broadcast",
+	"Multicast",
+	"Anycast",
+}
+
+// isTrafficMode ... Check if the given mode is a supported traffic pattern
+func isTrafficMode(mode string) bool {
+	for _, m := range trafficModes {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// buildFlows ... Generate the source/destination pairs for one round of the given traffic pattern
+func buildFlows(mode string, t routers.Template, rng *rand.Rand) ([]flow, error) {
+	n := len(t)
+	flows := make([]flow, 0, n)
+	switch mode {
+	case "One_To_All":
+		for i := 0; i < n; i++ {
+			flows = append(flows, flow{0, routers.RouterId(i)})
+		}
+	case "All_To_One":
+		for i := 0; i < n; i++ {
+			flows = append(flows, flow{routers.RouterId(i), 0})
+		}
+	case "All_To_All":
+		for i := 0; i < n; i++ {
+			for j := 0; j < n; j++ {
+				flows = append(flows, flow{routers.RouterId(i), routers.RouterId(j)})
+			}
+		}
+	case "Random_Pairs":
+		for i := 0; i < n; i++ {
+			src := rng.Intn(n)
+			dst := rng.Intn(n)
+			for n > 1 && dst == src {
+				dst = rng.Intn(n)
+			}
+			flows = append(flows, flow{routers.RouterId(src), routers.RouterId(dst)})
+		}
+	case "Permutation":
+		for i, j := range rng.Perm(n) {
+			flows = append(flows, flow{routers.RouterId(i), routers.RouterId(j)})
+		}
+	case "Bit_Reversal", "Transpose":
+		width, err := addressBits(mode, n)
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i < n; i++ {
+			var dst uint
+			if mode == "Bit_Reversal" {
+				dst = bits.Reverse(uint(i)) >> (bits.UintSize - width)
+			} else {
+				// Swap the upper and lower halves of the address, i.e. (x, y) -> (y, x)
+				half := width / 2
+				mask := uint(1)<<half - 1
+				dst = (uint(i)&mask)<<half | uint(i)>>half
+			}
+			flows = append(flows, flow{routers.RouterId(i), routers.RouterId(dst)})
+		}
+	case "Nearest_Neighbour":
+		for i, neighbours := range t {
+			for _, j := range neighbours {
+				flows = append(flows, flow{routers.RouterId(i), j})
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported test mode %s", mode)
+	}
+	return flows, nil
+}
+
+// addressBits ... Number of address bits for bit permutation patterns, requiring a power of two router count
+func addressBits(mode string, n int) (uint, error) {
+	if n == 0 || n&(n-1) != 0 {
+		return 0, fmt.Errorf("%s traffic requires a power of two number of routers (have %v)", mode, n)
+	}
+	width := uint(bits.TrailingZeros(uint(n)))
+	if mode == "Transpose" && width%2 != 0 {
+		return 0, fmt.Errorf("Transpose traffic requires an even power of two number of routers (have %v)", n)
+	}
+	return width, nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"log"
+
+	"routers"
+)
+
+// linkUtilisation ... Envelopes forwarded over a single directed link of the final network
+type linkUtilisation struct {
+	From      routers.RouterId `json:"from"`
+	To        routers.RouterId `json:"to"`
+	Envelopes uint64           `json:"envelopes"`
+}
+
+// collectUtilisation ... Forwarding count of every directed link in the template, including unused links
+func collectUtilisation(template routers.Template, states []routers.RouterState) []linkUtilisation {
+	counts := make(map[routers.RouterId]map[routers.RouterId]uint64, len(states))
+	for _, s := range states {
+		counts[s.ID] = s.Utilisation
+	}
+	links := make([]linkUtilisation, 0)
+	for from, neighbours := range template {
+		for _, to := range neighbours {
+			id := routers.RouterId(from)
+			links = append(links, linkUtilisation{From: id, To: to, Envelopes: counts[id][to]})
+		}
+	}
+	return links
+}
+
+// summariseUtilisation ... Distribution of forwarding counts across links
+func summariseUtilisation(links []linkUtilisation) summary {
+	samples := make([]float64, len(links))
+	for i, l := range links {
+		samples[i] = float64(l.Envelopes)
+	}
+	return summarise(samples)
+}
+
+// printUtilisation ... Log the spread of envelopes across links, and every link when verbose
+func printUtilisation(links []linkUtilisation, stats summary, verbose bool) {
+	log.Println("| -> Link Utilisation")
+	log.Printf("|    Minimum: %v, Maximum: %v\n", stats.Min, stats.Max)
+	log.Printf("|    Mean: %.3f, Std Dev: %.3f\n", stats.Mean, stats.StdDev)
+	if stats.Mean > 0 {
+		log.Printf("|    Imbalance (max / mean): %.3f\n", stats.Max/stats.Mean)
+	}
+	if !verbose {
+		return
+	}
+	for _, l := range links {
+		log.Printf("|    [%v] -> [%v]: %v\n", l.From, l.To, l.Envelopes)
+	}
+}
@@ -0,0 +1,149 @@
+package routers
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// edge ... An undirected link, with A <= B
+type edge struct {
+	A RouterId
+	B RouterId
+}
+
+func makeEdge(a RouterId, b RouterId) edge {
+	if b < a {
+		a, b = b, a
+	}
+	return edge{a, b}
+}
+
+// stateJSON ... Exported form of a RouterState
+type stateJSON struct {
+	ID               RouterId              `json:"id"`
+	Address          string                `json:"address"`
+	Network          string                `json:"network"`
+	Neighbours       map[RouterId]int      `json:"neighbour_channels"`
+	Table            *DVRTable             `json:"table"`
+	ShortestPathTree map[RouterId]RouterId `json:"shortest_path_tree"`
+	Utilisation      map[RouterId]uint64   `json:"utilisation"`
+	FastReroutes     uint64                `json:"fast_reroutes"`
+	Forwarding       ForwardingCounters    `json:"forwarding"`
+}
+
+// WriteStateJSON ... Write each router's address assignment, learned table and shortest path tree as JSON
+func WriteStateJSON(w io.Writer, states []RouterState) error {
+	doc := make([]stateJSON, len(states))
+	for i, s := range states {
+		doc[i] = stateJSON{
+			ID:               s.ID,
+			Address:          s.Address.String(),
+			Network:          s.Network.String(),
+			Neighbours:       s.Neighbours,
+			Table:            s.Table,
+			ShortestPathTree: s.ShortestPathTree(),
+			Utilisation:      s.Utilisation,
+			FastReroutes:     s.FastReroutes,
+			Forwarding:       s.Forwarding,
+		}
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(doc)
+}
+
+// WriteStateDOT ... Write the real topology overlaid with what the routers learned, followed by each router's
+// shortest path tree, as Graphviz DOT. Real links learned by every router are solid, real links missing from
+// some tables are dashed and labelled with how many routers learned them, and learned links absent from the
+// template are dotted red
+func WriteStateDOT(w io.Writer, t Template, states []RouterState) error {
+	b := bufio.NewWriter(w)
+	actual := make(map[edge]bool)
+	for id, neighbours := range t {
+		for _, n := range neighbours {
+			actual[makeEdge(RouterId(id), n)] = true
+		}
+	}
+	learned := make(map[edge]int)
+	for _, s := range states {
+		seen := make(map[edge]bool)
+		for _, id := range s.Table.Routers() {
+			for _, n := range s.Table.Neighbours(id) {
+				seen[makeEdge(id, n)] = true
+			}
+		}
+		for l := range seen {
+			learned[l]++
+		}
+	}
+	addresses := make(map[RouterId]IPv4, len(states))
+	for _, s := range states {
+		addresses[s.ID] = s.Address
+	}
+
+	fmt.Fprintln(b, "graph network {")
+	fmt.Fprintln(b, "  node [shape=box];")
+	for id := range t {
+		if addr, ok := addresses[RouterId(id)]; ok {
+			fmt.Fprintf(b, "  %v [label=\"%v\\n%v\"];\n", id, id, addr)
+		} else {
+			fmt.Fprintf(b, "  %v [label=\"%v\\nno state\", style=dashed];\n", id, id)
+		}
+	}
+	all := make([]edge, 0, len(actual)+len(learned))
+	for l := range actual {
+		all = append(all, l)
+	}
+	for l := range learned {
+		if !actual[l] {
+			all = append(all, l)
+		}
+	}
+	sortEdges(all)
+	for _, l := range all {
+		switch {
+		case !actual[l]:
+			fmt.Fprintf(b, "  %v -- %v [style=dotted, color=red, label=\"%v/%v\"];\n", l.A, l.B, learned[l], len(states))
+		case learned[l] < len(states):
+			fmt.Fprintf(b, "  %v -- %v [style=dashed, label=\"%v/%v\"];\n", l.A, l.B, learned[l], len(states))
+		default:
+			fmt.Fprintf(b, "  %v -- %v;\n", l.A, l.B)
+		}
+	}
+	fmt.Fprintln(b, "}")
+
+	for _, s := range states {
+		fmt.Fprintln(b)
+		writeTreeDOT(b, s)
+	}
+	return b.Flush()
+}
+
+// writeTreeDOT ... Write a router's shortest path tree as a DOT digraph rooted at the router
+func writeTreeDOT(w io.Writer, s RouterState) {
+	tree := s.ShortestPathTree()
+	children := make([]RouterId, 0, len(tree))
+	for id := range tree {
+		children = append(children, id)
+	}
+	sort.Slice(children, func(i, j int) bool { return children[i] < children[j] })
+	fmt.Fprintf(w, "digraph spt_%v {\n", s.ID)
+	fmt.Fprintf(w, "  %v [shape=doublecircle];\n", s.ID)
+	for _, id := range children {
+		fmt.Fprintf(w, "  %v -> %v;\n", tree[id], id)
+	}
+	fmt.Fprintln(w, "}")
+}
+
+// sortEdges ... Order edges by their lower then upper router
+func sortEdges(links []edge) {
+	sort.Slice(links, func(i, j int) bool {
+		if links[i].A != links[j].A {
+			return links[i].A < links[j].A
+		}
+		return links[i].B < links[j].B
+	})
+}
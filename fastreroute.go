@@ -0,0 +1,205 @@
+package routers
+
+import (
+	"log"
+	"sort"
+	"time"
+)
+
+// Dropout ... Fail the receiving router. It tells its neighbours their links to it are down, then silently
+// discards everything it receives other than state requests
+type Dropout struct{}
+
+// LinkDown ... Loss of the link to the neighbour {ID}, as detected by the physical layer
+type LinkDown struct {
+	ID RouterId
+}
+
+// LoopFreeAlternates ... For every destination reachable from {self}, the neighbours other than the least cost next
+// hops that can be used as a backup without the envelope looping back, i.e. dist(N, D) < dist(N, S) + dist(S, D).
+// Since a failed link can't be told apart from a failed neighbour, alternates must also be node protecting, their
+// path avoiding every primary next hop other than the destination itself. They are listed in order of cost via N
+func LoopFreeAlternates(table *DVRTable, self RouterId) map[RouterId][]RouterId {
+	costs, primary := equalCostFirstHops(table, self)
+	return loopFreeAlternates(table, self, costs, primary)
+}
+
+func loopFreeAlternates(table *DVRTable, self RouterId, costs map[RouterId]float64, primary map[RouterId][]RouterId) map[RouterId][]RouterId {
+	links := table.Links(self)
+	// Least costs from each neighbour, which includes every primary next hop
+	from := make(map[RouterId]map[RouterId]float64, len(links))
+	for n := range links {
+		from[n], _ = shortestPaths(table, n)
+	}
+	alternates := make(map[RouterId][]RouterId)
+	for dest, hops := range primary {
+		type candidate struct {
+			id   RouterId
+			cost float64
+		}
+		candidates := make([]candidate, 0)
+		for n, link := range links {
+			if containsRouter(hops, n) {
+				continue
+			}
+			viaN, ok := from[n][dest]
+			if !ok || viaN+costEpsilon >= from[n][self]+costs[dest] {
+				continue
+			}
+			protected := true
+			for _, p := range hops {
+				if p == dest {
+					// The destination itself can't be avoided
+					continue
+				}
+				if throughP, ok := from[n][p]; ok && viaN+costEpsilon >= throughP+from[p][dest] {
+					protected = false
+				}
+			}
+			if protected {
+				candidates = append(candidates, candidate{n, link + viaN})
+			}
+		}
+		if len(candidates) == 0 {
+			continue
+		}
+		sort.Slice(candidates, func(i, j int) bool {
+			a, b := candidates[i], candidates[j]
+			if a.cost != b.cost {
+				return a.cost < b.cost
+			}
+			return a.id < b.id
+		})
+		ids := make([]RouterId, len(candidates))
+		for i, c := range candidates {
+			ids[i] = c.id
+		}
+		alternates[dest] = ids
+	}
+	return alternates
+}
+
+func containsRouter(ids []RouterId, id RouterId) bool {
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+	return false
+}
+
+// forwardingTable ... A router's next hops for every destination, computed from a version of the routing table.
+// Once the routing table changes the old next hops stay in use for the convergence delay, during which envelopes
+// whose primary next hops have failed are sent to a loop free alternate
+type forwardingTable struct {
+	version     uint64
+	valid       bool
+	costs       map[RouterId]float64
+	primary     map[RouterId][]RouterId
+	alternates  map[RouterId][]RouterId
+	down        map[RouterId]bool
+	fastReroute bool
+	delay       time.Duration
+	saved       uint64 // Envelopes first sent to an alternate by this router because their primary next hops had failed
+}
+
+func newForwardingTable(cfg Config) *forwardingTable {
+	return &forwardingTable{
+		down:        make(map[RouterId]bool),
+		fastReroute: cfg.FastReroute,
+		delay:       cfg.ConvergenceDelay,
+	}
+}
+
+// refresh ... Recompute the next hops once the convergence delay has passed since the routing table last changed.
+// The delay runs from the change itself, not from the first envelope to find the next hops stale
+func (f *forwardingTable) refresh(table *DVRTable, self RouterId, now time.Time) {
+	version, changed := table.Changed()
+	if f.valid && version == f.version {
+		return
+	}
+	if f.valid && f.delay > 0 && now.Sub(changed) < f.delay {
+		return
+	}
+	f.version, f.valid = version, true
+	f.costs, f.primary = equalCostFirstHops(table, self)
+	if f.fastReroute {
+		f.alternates = loopFreeAlternates(table, self, f.costs, f.primary)
+	}
+}
+
+// nextHops ... Usable least cost next hops to {dest} and their cost. When every primary next hop has failed the
+// first usable loop free alternate is returned instead, with rerouted set
+func (f *forwardingTable) nextHops(table *DVRTable, self RouterId, dest RouterId, NMap NeighbourMap) (hops []RouterId, cost float64, rerouted bool) {
+	f.refresh(table, self, time.Now())
+	usable := func(ids []RouterId) []RouterId {
+		result := make([]RouterId, 0, len(ids))
+		for _, id := range ids {
+			// Only follow a path once the neighbour's channel is known
+			if _, ok := NMap[id]; ok && !f.down[id] {
+				result = append(result, id)
+			}
+		}
+		return result
+	}
+	primary := f.primary[dest]
+	if hops = usable(primary); len(hops) > 0 || !f.fastReroute {
+		return hops, f.costs[dest], false
+	}
+	for _, id := range primary {
+		if !f.down[id] {
+			continue
+		}
+		if alternates := usable(f.alternates[dest]); len(alternates) > 0 {
+			return alternates[:1], f.costs[dest], true
+		}
+		break
+	}
+	return nil, 0, false
+}
+
+// fail ... Mark the neighbour as down, so it is never forwarded to again
+func (f *forwardingTable) fail(neighbour RouterId) {
+	f.down[neighbour] = true
+}
+
+// restore ... Mark the neighbour as up again once a link to it is back
+func (f *forwardingTable) restore(neighbour RouterId) {
+	delete(f.down, neighbour)
+}
+
+// live ... Indexes of the neighbour channels not known to have failed
+func (f *forwardingTable) live(neighbours []chan<- interface{}, NMap NeighbourMap) []int {
+	down := make(Routers, 0, len(f.down))
+	for id := range f.down {
+		down = append(down, id)
+	}
+	return NMap.getAllNotIn(down, len(neighbours))
+}
+
+// ---- Dropout ----
+
+// processDropout ... Tell every neighbour its link to this router is down
+func processDropout(logLevel string, self RouterId, networkAddress IPv4, neighbours []chan<- interface{}, done <-chan struct{}) {
+	if logLevel != "none" {
+		log.Printf("[%v] Dropping out of the network", networkAddress.toString(false))
+	}
+	for _, n := range neighbours {
+		go sendOrStop(n, LinkDown{ID: self}, done)
+	}
+}
+
+// processLinkDown ... Stop forwarding to the failed neighbour and advertise the loss of the link
+func processLinkDown(logLevel string, msg LinkDown, self RouterId, networkAddress IPv4, RouterIPAddress IPv4, RoutingTable *DVRTable, neighbours []chan<- interface{}, st *routerState) {
+	if st.fib.down[msg.ID] {
+		return
+	}
+	if logLevel != "none" {
+		log.Printf("[%v] Link to neighbour [%v] is down", networkAddress.toString(false), msg.ID)
+	}
+	st.fib.fail(msg.ID)
+	st.labels.linkDown(msg.ID)
+	RoutingTable.RemoveLink(self, msg.ID)
+	RoutingTable.RemoveLink(msg.ID, self)
+	advertiseLinks(logLevel, self, networkAddress, RouterIPAddress, RoutingTable, neighbours, st)
+}
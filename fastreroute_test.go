@@ -0,0 +1,110 @@
+package routers
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLoopFreeAlternates(t *testing.T) {
+	triangle := Template{{1, 2}, {0, 2}, {0, 1}}
+	ring := Template{{1, 3}, {0, 2}, {1, 3}, {2, 0}}
+	// 2's path to 3 avoids 1, the primary next hop
+	protecting := WeightedTemplate{Template{{1, 2}, {0, 3}, {0, 3}, {1, 2}}, Costs{{2, 3}: 1.5, {3, 2}: 1.5}}
+	// 2's least cost path to 3 runs through 1, so it can't protect against 1 failing
+	crossing := WeightedTemplate{Template{{1, 2}, {0, 2, 3}, {0, 1}, {1}}, Costs{{0, 2}: 3, {2, 0}: 3}}
+	// 2 and 3 are both alternates to 4, 2 the cheaper
+	ordered := WeightedTemplate{
+		Template{{1, 2, 3}, {0, 4}, {0, 4}, {0, 4}, {1, 2, 3}},
+		Costs{{0, 2}: 2, {2, 0}: 2, {3, 4}: 2.5, {4, 3}: 2.5},
+	}
+	tests := []struct {
+		name     string
+		weighted WeightedTemplate
+		self     RouterId
+		dest     RouterId
+		want     []RouterId
+	}{
+		{"triangle", WeightedTemplate{Template: triangle}, 0, 1, []RouterId{2}},
+		{"square loops back", WeightedTemplate{Template: ring}, 0, 1, nil},
+		{"every neighbour a primary", WeightedTemplate{Template: ring}, 0, 2, nil},
+		{"node protecting", protecting, 0, 3, []RouterId{2}},
+		{"path crosses the primary", crossing, 0, 3, nil},
+		{"destination is the primary", crossing, 0, 1, []RouterId{2}},
+		{"ordered by cost", ordered, 0, 4, []RouterId{2, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			alternates := LoopFreeAlternates(TableFromTemplate(tt.weighted.Template, tt.weighted.Costs), tt.self)
+			if got := alternates[tt.dest]; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("LoopFreeAlternates(%v)[%v] = %v, want %v", tt.self, tt.dest, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestForwardingTableNextHops(t *testing.T) {
+	// Ring 0-1-3-2-0 with a costly diagonal from 0 to 3, which is a loop free alternate to 1
+	table := TableFromTemplate(Template{{1, 2, 3}, {0, 3}, {0, 3}, {1, 2, 0}}, Costs{{0, 3}: 5, {3, 0}: 5})
+	known := NeighbourMap{1: 0, 2: 1, 3: 2}
+	tests := []struct {
+		name        string
+		fastReroute bool
+		NMap        NeighbourMap
+		down        []RouterId
+		dest        RouterId
+		want        []RouterId
+		cost        float64
+		rerouted    bool
+	}{
+		{"primary", false, known, nil, 1, []RouterId{1}, 1, false},
+		{"equal cost primaries", false, known, nil, 3, []RouterId{1, 2}, 2, false},
+		{"one primary down", true, known, []RouterId{1}, 3, []RouterId{2}, 2, false},
+		{"unmapped primary skipped", false, NeighbourMap{2: 1, 3: 2}, nil, 3, []RouterId{2}, 2, false},
+		{"primary down without fast reroute", false, known, []RouterId{1}, 1, []RouterId{}, 1, false},
+		{"primary down", true, known, []RouterId{1}, 1, []RouterId{3}, 1, true},
+		{"primary and alternate down", true, known, []RouterId{1, 3}, 1, nil, 0, false},
+		{"primary unmapped rather than down", true, NeighbourMap{2: 1, 3: 2}, nil, 1, nil, 0, false},
+		{"unreachable", true, known, nil, 7, nil, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newForwardingTable(Config{FastReroute: tt.fastReroute})
+			for _, id := range tt.down {
+				f.fail(id)
+			}
+			hops, cost, rerouted := f.nextHops(table, 0, tt.dest, tt.NMap)
+			if !reflect.DeepEqual(hops, tt.want) || cost != tt.cost || rerouted != tt.rerouted {
+				t.Errorf("nextHops(%v) = %v, %v, %v, want %v, %v, %v",
+					tt.dest, hops, cost, rerouted, tt.want, tt.cost, tt.rerouted)
+			}
+		})
+	}
+}
+
+func TestForwardingTableConvergence(t *testing.T) {
+	table := TableFromTemplate(Template{{1, 2}, {0, 2}, {0, 1}}, nil)
+	f := newForwardingTable(Config{ConvergenceDelay: 50 * time.Millisecond})
+	f.refresh(table, 0, time.Now())
+	table.RemoveLink(0, 1)
+	table.RemoveLink(1, 0)
+	_, changed := table.Changed()
+	tests := []struct {
+		name  string
+		after time.Duration // Since the table changed
+		want  []RouterId
+	}{
+		// The first lookup comes late, but the delay still runs from the change
+		{"old next hop kept", 40 * time.Millisecond, []RouterId{1}},
+		{"recomputed", 60 * time.Millisecond, []RouterId{2}},
+		{"kept once recomputed", 70 * time.Millisecond, []RouterId{2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f.refresh(table, 0, changed.Add(tt.after))
+			if got := f.primary[1]; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("next hops to 1 after %v = %v, want %v", tt.after, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package routers
+
+import (
+	"math"
+	"sort"
+)
+
+// geographicRouting ... Greedy Perimeter Stateless Routing (GPSR). Envelopes go to the neighbour closest to the
+// destination's position, and around the faces of the planarised neighbour graph by the right hand rule when no
+// neighbour is closer than the router itself
+type geographicRouting struct {
+	positions [][2]float64
+}
+
+func (g *geographicRouting) name() string {
+	return "geographic routing"
+}
+
+func (g *geographicRouting) nextHop(ctx hopContext, msg *Envelope) (RouterId, bool) {
+	if int(ctx.self) >= len(g.positions) || int(msg.Dest) >= len(g.positions) {
+		return 0, false
+	}
+	neighbours := make([]RouterId, 0, len(ctx.NMap))
+	for id := range ctx.NMap {
+		if int(id) < len(g.positions) && ctx.usable(id) {
+			neighbours = append(neighbours, id)
+		}
+	}
+	if len(neighbours) == 0 {
+		return 0, false
+	}
+	sort.Slice(neighbours, func(i, j int) bool { return neighbours[i] < neighbours[j] })
+	here, dest := g.positions[ctx.self], g.positions[msg.Dest]
+	if msg.perimeter && distance(here, dest) < distance(msg.entry, dest) {
+		msg.perimeter = false
+	}
+	if !msg.perimeter {
+		best, closest, found := RouterId(0), distance(here, dest), false
+		for _, n := range neighbours {
+			if d := distance(g.positions[n], dest); d < closest {
+				best, closest, found = n, d, true
+			}
+		}
+		if found {
+			return best, true
+		}
+		// A local minimum, so start walking the face the line towards the destination crosses
+		planar := g.planar(ctx.self, neighbours)
+		next := g.counterclockwise(here, bearing(here, dest), planar)
+		msg.perimeter = true
+		msg.entry, msg.crossing = here, here
+		msg.firstEdge = Link{ctx.self, next}
+		return next, true
+	}
+	planar := g.planar(ctx.self, neighbours)
+	next := g.counterclockwise(here, bearing(here, g.positions[msg.previous]), planar)
+	if (Link{ctx.self, next}) == msg.firstEdge {
+		// Walked the whole face without getting closer
+		return 0, false
+	}
+	next = g.changeFace(ctx.self, next, planar, msg)
+	return next, true
+}
+
+// planar ... The neighbours linked to {self} in the Gabriel graph, those with no other neighbour inside the circle
+// whose diameter is the link. Walking the faces of the planar graph can't cross back over itself
+func (g *geographicRouting) planar(self RouterId, neighbours []RouterId) []RouterId {
+	here := g.positions[self]
+	kept := make([]RouterId, 0, len(neighbours))
+	for _, v := range neighbours {
+		there := g.positions[v]
+		middle := [2]float64{(here[0] + there[0]) / 2, (here[1] + there[1]) / 2}
+		radius := distance(here, there) / 2
+		witnessed := false
+		for _, w := range neighbours {
+			if w != v && distance(g.positions[w], middle) < radius-costEpsilon {
+				witnessed = true
+				break
+			}
+		}
+		if !witnessed {
+			kept = append(kept, v)
+		}
+	}
+	if len(kept) == 0 {
+		// Only possible with coincident positions, so keep every link rather than strand the envelope
+		return neighbours
+	}
+	return kept
+}
+
+// counterclockwise ... The first of {candidates} swept counterclockwise about {here} from the bearing {from},
+// the bearing itself coming last
+func (g *geographicRouting) counterclockwise(here [2]float64, from float64, candidates []RouterId) RouterId {
+	best, smallest := candidates[0], math.Inf(1)
+	for _, n := range candidates {
+		turn := bearing(here, g.positions[n]) - from
+		for turn <= costEpsilon {
+			turn += 2 * math.Pi
+		}
+		for turn > 2*math.Pi+costEpsilon {
+			turn -= 2 * math.Pi
+		}
+		if turn < smallest {
+			best, smallest = n, turn
+		}
+	}
+	return best
+}
+
+// changeFace ... Move onto the next face while the link to {next} crosses the line from where perimeter routing
+// began to the destination closer to the destination than the last crossing
+func (g *geographicRouting) changeFace(self RouterId, next RouterId, planar []RouterId, msg *Envelope) RouterId {
+	here, dest := g.positions[self], g.positions[msg.Dest]
+	for range planar {
+		point, ok := intersection(here, g.positions[next], msg.entry, dest)
+		if !ok || distance(point, dest) >= distance(msg.crossing, dest)-costEpsilon {
+			break
+		}
+		msg.crossing = point
+		next = g.counterclockwise(here, bearing(here, g.positions[next]), planar)
+		msg.firstEdge = Link{self, next}
+	}
+	return next
+}
+
+// distance ... Euclidean distance between two positions
+func distance(a [2]float64, b [2]float64) float64 {
+	return math.Hypot(a[0]-b[0], a[1]-b[1])
+}
+
+// bearing ... Angle of the direction from {a} to {b}
+func bearing(a [2]float64, b [2]float64) float64 {
+	return math.Atan2(b[1]-a[1], b[0]-a[0])
+}
+
+// intersection ... Where the segments {a}-{b} and {c}-{d} cross, if they do
+func intersection(a [2]float64, b [2]float64, c [2]float64, d [2]float64) ([2]float64, bool) {
+	r := [2]float64{b[0] - a[0], b[1] - a[1]}
+	s := [2]float64{d[0] - c[0], d[1] - c[1]}
+	denominator := r[0]*s[1] - r[1]*s[0]
+	if math.Abs(denominator) < costEpsilon {
+		return [2]float64{}, false
+	}
+	t := ((c[0]-a[0])*s[1] - (c[1]-a[1])*s[0]) / denominator
+	u := ((c[0]-a[0])*r[1] - (c[1]-a[1])*r[0]) / denominator
+	if t < 0 || t > 1 || u < 0 || u > 1 {
+		return [2]float64{}, false
+	}
+	return [2]float64{a[0] + t*r[0], a[1] + t*r[1]}, true
+}
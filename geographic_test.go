@@ -0,0 +1,122 @@
+package routers
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGeographicRouting(t *testing.T) {
+	// A U shaped chain 0-1-2-3 around a void, 0 closer to 4 than any of its neighbours
+	void := [][2]float64{{0, 0}, {0, 1.5}, {1.5, 2}, {3, 1.5}, {3, 0}}
+	tests := []struct {
+		name      string
+		template  Template
+		positions [][2]float64
+		source    RouterId
+		dest      RouterId
+		want      Path // Routers visited, the source first
+		delivered bool
+	}{
+		{
+			"greedy",
+			Template{{1}, {0, 2}, {1}},
+			[][2]float64{{0, 0}, {1, 0}, {2, 0}},
+			0, 2,
+			Path{0, 1, 2},
+			true,
+		},
+		{
+			"closest neighbour",
+			Template{{1, 2, 3}, {0, 3}, {0, 3}, {1, 2, 0}},
+			[][2]float64{{0, 0}, {1, 1}, {1.5, -0.5}, {2, 0}},
+			0, 3,
+			Path{0, 3},
+			true,
+		},
+		{
+			"around a void",
+			Template{{1}, {0, 2}, {1, 3}, {2, 4}, {3}},
+			void,
+			0, 4,
+			Path{0, 1, 2, 3, 4},
+			true,
+		},
+		{
+			"unreachable",
+			Template{{1}, {0, 2}, {1, 3}, {2}, {}},
+			void,
+			0, 4,
+			Path{0, 1, 2, 3, 2, 1, 0, 1, 2, 3},
+			false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := &geographicRouting{positions: tt.positions}
+			msg := Envelope{Source: tt.source, Dest: tt.dest}
+			path, delivered := Path{tt.source}, false
+			for current := tt.source; len(path) <= 2*len(tt.template)*len(tt.template); {
+				NMap := make(NeighbourMap, len(tt.template[current]))
+				for i, id := range tt.template[current] {
+					NMap[id] = i
+				}
+				next, ok := g.nextHop(hopContext{current, NMap, newBalancer(""), newForwardingTable(Config{})}, &msg)
+				if !ok {
+					break
+				}
+				msg.previous, current = current, next
+				path = append(path, current)
+				if current == tt.dest {
+					delivered = true
+					break
+				}
+			}
+			if !reflect.DeepEqual(path, tt.want) || delivered != tt.delivered {
+				t.Errorf("routed %v, delivered %v, want %v, delivered %v", path, delivered, tt.want, tt.delivered)
+			}
+		})
+	}
+}
+
+func TestGabrielPlanar(t *testing.T) {
+	positions := [][2]float64{{0, 0}, {2, 0}, {1, 0.1}, {0, 2}, {-1, -1}}
+	tests := []struct {
+		name       string
+		neighbours []RouterId
+		want       []RouterId
+	}{
+		{"no witnesses", []RouterId{1, 3, 4}, []RouterId{1, 3, 4}},
+		{"witnessed link dropped", []RouterId{1, 2, 3}, []RouterId{2, 3}},
+		{"single link", []RouterId{1}, []RouterId{1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := &geographicRouting{positions: positions}
+			if got := g.planar(0, tt.neighbours); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("planar(%v) = %v, want %v", tt.neighbours, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIntersection(t *testing.T) {
+	tests := []struct {
+		name       string
+		a, b, c, d [2]float64
+		want       [2]float64
+		ok         bool
+	}{
+		{"crossing", [2]float64{0, 0}, [2]float64{2, 2}, [2]float64{0, 2}, [2]float64{2, 0}, [2]float64{1, 1}, true},
+		{"touching", [2]float64{0, 0}, [2]float64{1, 0}, [2]float64{1, -1}, [2]float64{1, 1}, [2]float64{1, 0}, true},
+		{"parallel", [2]float64{0, 0}, [2]float64{1, 0}, [2]float64{0, 1}, [2]float64{1, 1}, [2]float64{}, false},
+		{"short of each other", [2]float64{0, 0}, [2]float64{1, 1}, [2]float64{3, 0}, [2]float64{2, 1}, [2]float64{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := intersection(tt.a, tt.b, tt.c, tt.d)
+			if ok != tt.ok || distance(got, tt.want) > costEpsilon {
+				t.Errorf("intersection(%v-%v, %v-%v) = %v, %v, want %v, %v", tt.a, tt.b, tt.c, tt.d, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
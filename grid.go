@@ -0,0 +1,175 @@
+package routers
+
+import "fmt"
+
+// Grid ... Coordinates of every router in a mesh, torus or hypercube (a mesh of side 2), for routing computed
+// from the coordinates rather than the routing table
+type Grid struct {
+	Coordinates [][]int // Position of each router, indexed by RouterId
+	Wrap        bool    // Links wrap around each dimension, as in a torus
+}
+
+// validate ... Check every router of {t} has a distinct, non-negative position with the same number of dimensions,
+// and is linked to exactly the routers one step away along a single dimension, as routing by coordinates assumes
+func (g Grid) validate(t Template) error {
+	if len(g.Coordinates) != len(t) {
+		return fmt.Errorf("grid has coordinates for %v routers, expected %v", len(g.Coordinates), len(t))
+	}
+	seen := make(map[string]RouterId, len(t))
+	var sizes []int
+	if len(g.Coordinates) > 0 {
+		sizes = make([]int, len(g.Coordinates[0]))
+	}
+	for id, c := range g.Coordinates {
+		if len(c) != len(g.Coordinates[0]) {
+			return fmt.Errorf("router [%v] has %v coordinates, expected %v", id, len(c), len(g.Coordinates[0]))
+		}
+		key := fmt.Sprint(c)
+		if other, ok := seen[key]; ok {
+			return fmt.Errorf("routers [%v] and [%v] share coordinates %v", other, id, c)
+		}
+		seen[key] = RouterId(id)
+		for d, v := range c {
+			if v < 0 {
+				return fmt.Errorf("router [%v] has negative coordinates %v", id, c)
+			}
+			if v+1 > sizes[d] {
+				sizes[d] = v + 1
+			}
+		}
+	}
+	for id, c := range g.Coordinates {
+		var neighbours []RouterId
+		for d := range c {
+			for _, step := range []int{-1, 1} {
+				n := append([]int(nil), c...)
+				n[d] += step
+				if g.Wrap {
+					n[d] = (n[d] + sizes[d]) % sizes[d]
+				}
+				if other, ok := seen[fmt.Sprint(n)]; ok && other != RouterId(id) && !hasLink(neighbours, other) {
+					neighbours = append(neighbours, other)
+				}
+			}
+		}
+		for _, n := range t[id] {
+			if !hasLink(neighbours, n) {
+				return fmt.Errorf("router [%v] at %v links to [%v] at %v, which isn't a grid neighbour",
+					id, c, n, g.Coordinates[n])
+			}
+		}
+		for _, n := range neighbours {
+			if !hasLink(t[id], n) {
+				return fmt.Errorf("router [%v] at %v has no link to its grid neighbour [%v] at %v",
+					id, c, n, g.Coordinates[n])
+			}
+		}
+	}
+	return nil
+}
+
+// gridRouting ... Dimension order routing, correcting each dimension in turn (XY routing on a mesh, e-cube on a
+// hypercube), or minimal adaptive routing, taking the least loaded of every hop that moves closer to the destination
+type gridRouting struct {
+	grid     Grid
+	adaptive bool
+	sizes    []int
+	routers  map[int]RouterId // Router at each position, keyed by its mixed radix index
+}
+
+func newGridRouting(g Grid, adaptive bool) *gridRouting {
+	r := &gridRouting{grid: g, adaptive: adaptive, routers: make(map[int]RouterId, len(g.Coordinates))}
+	if len(g.Coordinates) > 0 {
+		r.sizes = make([]int, len(g.Coordinates[0]))
+	}
+	for _, c := range g.Coordinates {
+		for d, v := range c {
+			if v+1 > r.sizes[d] {
+				r.sizes[d] = v + 1
+			}
+		}
+	}
+	for id, c := range g.Coordinates {
+		r.routers[r.index(c)] = RouterId(id)
+	}
+	return r
+}
+
+func (r *gridRouting) name() string {
+	if r.adaptive {
+		return "minimal adaptive routing"
+	}
+	return "dimension order routing"
+}
+
+// index ... Mixed radix index of a position
+func (r *gridRouting) index(c []int) int {
+	i := 0
+	for d := len(c) - 1; d >= 0; d-- {
+		i = i*r.sizes[d] + c[d]
+	}
+	return i
+}
+
+// step ... The router one hop from {from} in dimension {d} and direction {dir}
+func (r *gridRouting) step(from []int, d int, dir int) (RouterId, bool) {
+	c := append([]int(nil), from...)
+	c[d] += dir
+	if r.grid.Wrap {
+		c[d] = (c[d] + r.sizes[d]) % r.sizes[d]
+	}
+	id, ok := r.routers[r.index(c)]
+	return id, ok
+}
+
+// directions ... Directions in dimension {d} that move from {from} closer to {to}, both when the two ways round
+// a torus are equally short
+func (r *gridRouting) directions(d int, from int, to int) []int {
+	if from == to {
+		return nil
+	}
+	if !r.grid.Wrap {
+		if to > from {
+			return []int{1}
+		}
+		return []int{-1}
+	}
+	forwards := (to - from + r.sizes[d]) % r.sizes[d]
+	backwards := r.sizes[d] - forwards
+	switch {
+	case forwards < backwards:
+		return []int{1}
+	case backwards < forwards:
+		return []int{-1}
+	default:
+		return []int{1, -1}
+	}
+}
+
+func (r *gridRouting) nextHop(ctx hopContext, msg *Envelope) (RouterId, bool) {
+	if int(ctx.self) >= len(r.grid.Coordinates) || int(msg.Dest) >= len(r.grid.Coordinates) {
+		return 0, false
+	}
+	from, to := r.grid.Coordinates[ctx.self], r.grid.Coordinates[msg.Dest]
+	best, found := RouterId(0), false
+	for d := range from {
+		directions := r.directions(d, from[d], to[d])
+		for _, dir := range directions {
+			id, ok := r.step(from, d, dir)
+			if !ok || !ctx.usable(id) {
+				continue
+			}
+			if !r.adaptive {
+				return id, true
+			}
+			if !found || ctx.balance.load(id) < ctx.balance.load(best) {
+				best, found = id, true
+			}
+		}
+		if !r.adaptive && len(directions) > 0 {
+			// Dimension order routing never skips ahead to a later dimension
+			return 0, false
+		}
+	}
+	return best, found
+}
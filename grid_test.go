@@ -0,0 +1,104 @@
+package routers
+
+import "testing"
+
+// gridOf ... Coordinates of every router of a mesh with the given side lengths, RouterIds counting along the first
+// dimension fastest
+func gridOf(sides ...int) [][]int {
+	count := 1
+	for _, s := range sides {
+		count *= s
+	}
+	coordinates := make([][]int, count)
+	for id := range coordinates {
+		c, rest := make([]int, len(sides)), id
+		for d, s := range sides {
+			c[d], rest = rest%s, rest/s
+		}
+		coordinates[id] = c
+	}
+	return coordinates
+}
+
+func TestGridValidate(t *testing.T) {
+	mesh := Template{{1, 3}, {0, 2, 4}, {1, 5}, {0, 4, 6}, {1, 3, 5, 7}, {2, 4, 8}, {3, 7}, {4, 6, 8}, {5, 7}}
+	square := Template{{1, 2}, {0, 3}, {0, 3}, {1, 2}}
+	ring := Template{{1, 3}, {0, 2}, {1, 3}, {0, 2}}
+	tests := []struct {
+		name     string
+		grid     Grid
+		template Template
+		wantErr  bool
+	}{
+		{"mesh", Grid{Coordinates: gridOf(3, 3)}, mesh, false},
+		{"torus", Grid{Coordinates: gridOf(4), Wrap: true}, ring, false},
+		{"torus of side 2", Grid{Coordinates: gridOf(2, 2), Wrap: true}, square, false},
+		{"too few routers", Grid{Coordinates: gridOf(2, 2)}, append(square, Template{{}}...), true},
+		{"mixed dimensions", Grid{Coordinates: [][]int{{0, 0}, {1}}}, Template{{1}, {0}}, true},
+		{"shared coordinates", Grid{Coordinates: [][]int{{0, 0}, {1, 0}, {0, 0}}}, Template{{1}, {0, 2}, {1}}, true},
+		{"negative coordinates", Grid{Coordinates: [][]int{{-1}, {0}}}, Template{{1}, {0}}, true},
+		{"diagonal link", Grid{Coordinates: gridOf(2, 2)}, Template{{1, 2, 3}, {0, 3}, {0, 3}, {0, 1, 2}}, true},
+		{"missing link", Grid{Coordinates: gridOf(4)}, Template{{1}, {0}, {3}, {2}}, true},
+		{"wrap link without wrap", Grid{Coordinates: gridOf(4)}, ring, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.grid.validate(tt.template); (err != nil) != tt.wantErr {
+				t.Errorf("validate(%v) = %v, want error %v", tt.template, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGridRouting(t *testing.T) {
+	mesh := Grid{Coordinates: gridOf(3, 3)}
+	ring := Grid{Coordinates: gridOf(4), Wrap: true}
+	cube := Grid{Coordinates: gridOf(2, 2, 2)}
+	tests := []struct {
+		name     string
+		grid     Grid
+		adaptive bool
+		self     RouterId
+		dest     RouterId
+		down     []RouterId
+		loaded   []RouterId // Neighbours with an envelope waiting to be taken
+		want     RouterId
+		ok       bool
+	}{
+		{"first dimension first", mesh, false, 0, 8, nil, nil, 1, true},
+		{"backwards", mesh, false, 2, 6, nil, nil, 1, true},
+		{"first dimension done", mesh, false, 1, 7, nil, nil, 4, true},
+		{"never skips ahead", mesh, false, 0, 8, []RouterId{1}, nil, 0, false},
+		{"wraps the short way", ring, false, 0, 3, nil, nil, 3, true},
+		{"either way round", ring, false, 0, 2, nil, nil, 1, true},
+		{"other way round", ring, false, 0, 2, []RouterId{1}, nil, 3, true},
+		{"e-cube", cube, false, 0, 7, nil, nil, 1, true},
+		{"e-cube last dimension", cube, false, 3, 7, nil, nil, 7, true},
+		{"arrived", mesh, false, 4, 4, nil, nil, 0, false},
+		{"outside the grid", mesh, false, 0, 9, nil, nil, 0, false},
+		{"adaptive least loaded", mesh, true, 0, 8, nil, []RouterId{1}, 3, true},
+		{"adaptive lowest dimension among equals", mesh, true, 0, 8, nil, nil, 1, true},
+		{"adaptive around failure", mesh, true, 0, 8, []RouterId{1}, nil, 3, true},
+		{"adaptive only minimal hops", mesh, true, 0, 2, []RouterId{1}, nil, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			NMap := make(NeighbourMap, len(tt.grid.Coordinates))
+			for id := range tt.grid.Coordinates {
+				NMap[RouterId(id)] = id
+			}
+			ctx := hopContext{tt.self, NMap, newBalancer(""), newForwardingTable(Config{})}
+			for _, id := range tt.down {
+				ctx.fib.fail(id)
+			}
+			for _, id := range tt.loaded {
+				ctx.balance.queue(id)
+			}
+			msg := Envelope{Dest: tt.dest}
+			got, ok := newGridRouting(tt.grid, tt.adaptive).nextHop(ctx, &msg)
+			if ok != tt.ok || (ok && got != tt.want) {
+				t.Errorf("nextHop(%v -> %v) = %v, %v, want %v, %v", tt.self, tt.dest, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,179 @@
+package routers
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+	"sort"
+)
+
+// allocationModes ... Supported values of Config.Allocation
+var allocationModes = []string{"", "random", "sequential", "negotiated"}
+
+// defaultSupernet ... Block the sequential and negotiated modes allocate from when Config.Supernet is unset
+var defaultSupernet = IPv4{10, 0, 0, 0, 8}
+
+// validAllocation ... Check the allocation mode is supported and its supernet is a valid CIDR block
+func validAllocation(cfg Config) error {
+	supported := false
+	for _, m := range allocationModes {
+		supported = supported || m == cfg.Allocation
+	}
+	if !supported {
+		return fmt.Errorf("unsupported allocation mode %q (expected random, sequential or negotiated)", cfg.Allocation)
+	}
+	if cfg.Supernet.Prefix > 32 {
+		return fmt.Errorf("supernet %v has a prefix longer than 32 bits", cfg.Supernet)
+	}
+	return nil
+}
+
+// supernetOf ... The configured supernet with its host bits cleared, or the default
+func supernetOf(cfg Config) IPv4 {
+	if cfg.Supernet == (IPv4{}) {
+		return defaultSupernet
+	}
+	return ipv4FromUint32(maskTo(cfg.Supernet.toUint32(), cfg.Supernet.Prefix), cfg.Supernet.Prefix)
+}
+
+// blockPrefix ... CIDR prefix of the smallest block housing a router and its {neighbours}
+func blockPrefix(neighbours int) uint {
+	return ipCountToPrefix(neighbours + 1)
+}
+
+// blockAddress ... The router's address in the block starting at {network}, its first host. Blocks too small to
+// have hosts apart from their network and broadcast addresses use the network address
+func blockAddress(network uint32, prefix uint) IPv4 {
+	if prefix >= 31 {
+		return ipv4FromUint32(network, prefix)
+	}
+	return ipv4FromUint32(network+1, prefix)
+}
+
+// Overlaps ... Check the two CIDR blocks share any address
+func (ip IPv4) Overlaps(other IPv4) bool {
+	shorter := ip.Prefix
+	if other.Prefix < shorter {
+		shorter = other.Prefix
+	}
+	return maskTo(ip.toUint32(), shorter) == maskTo(other.toUint32(), shorter)
+}
+
+// AllocateBlocks ... Carve a block sized by neighbour count for every router of the template from {supernet},
+// without overlap. Blocks are handed out largest first (lowest RouterId among equals) from the start of the
+// supernet, so every block is aligned to its size and the allocation is the same on every run
+func AllocateBlocks(supernet IPv4, t Template) ([]IPv4, error) {
+	order := make([]int, len(t))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return blockPrefix(len(t[order[i]])) < blockPrefix(len(t[order[j]]))
+	})
+	base := uint64(maskTo(supernet.toUint32(), supernet.Prefix))
+	limit := base + uint64(1)<<(32-supernet.Prefix)
+	next := base
+	blocks := make([]IPv4, len(t))
+	for _, id := range order {
+		prefix := blockPrefix(len(t[id]))
+		if prefix < supernet.Prefix || next+uint64(1)<<(32-prefix) > limit {
+			return nil, fmt.Errorf("supernet %v is too small for the blocks of %v routers", supernet, len(t))
+		}
+		blocks[id] = blockAddress(uint32(next), prefix)
+		next += uint64(1) << (32 - prefix)
+	}
+	return blocks, nil
+}
+
+// freeBlock ... A block with {prefix} in {supernet} overlapping none of the {taken} blocks. The search starts at a
+// random block, so that routers claiming at once are unlikely to pick the same one, and reports false should the
+// supernet be full
+func freeBlock(rng *rand.Rand, supernet IPv4, prefix uint, taken []IPv4) (IPv4, bool) {
+	if prefix < supernet.Prefix {
+		return IPv4{}, false
+	}
+	base := maskTo(supernet.toUint32(), supernet.Prefix)
+	count := uint64(1) << (prefix - supernet.Prefix)
+	start := uint64(rng.Int63n(int64(count)))
+	for i := uint64(0); i < count; i++ {
+		network := base + uint32(((start+i)%count)<<(32-prefix))
+		candidate := blockAddress(network, prefix)
+		free := true
+		for _, t := range taken {
+			if candidate.Overlaps(t) {
+				free = false
+				break
+			}
+		}
+		if free {
+			return candidate, true
+		}
+	}
+	return IPv4{}, false
+}
+
+// takenBlocks ... Prefixes advertised by every router other than {self}
+func takenBlocks(RoutingTable *DVRTable, self RouterId) []IPv4 {
+	taken := make([]IPv4, 0)
+	for id, prefixes := range RoutingTable.AdvertisedPrefixes() {
+		if id != self {
+			taken = append(taken, prefixes...)
+		}
+	}
+	return taken
+}
+
+// initialAddress ... The router's block under the allocation mode, random (as before allocation existed), carved
+// out up front or, when negotiated, claimed at random from the supernet until a conflict moves it
+func initialAddress(self RouterId, neighbours int, cfg Config, rng *rand.Rand) IPv4 {
+	switch cfg.Allocation {
+	case "sequential":
+		return cfg.Blocks[self]
+	case "negotiated":
+		if block, ok := freeBlock(rng, supernetOf(cfg), blockPrefix(neighbours), nil); ok {
+			return block
+		}
+	}
+	// Assign a new local network IP with subnet range poer of 2 encapsulating all neighbours
+	return randomIPv4FromSubetSize(rng, neighbours+1)
+}
+
+// negotiateAddress ... Move the router to a free block should a router with a lower RouterId advertise a block
+// overlapping its own, which keeps the block. The new block is advertised at once, so any router it in turn
+// conflicts with learns of it. Returns the router's address and network address, changed or not
+func negotiateAddress(logLevel string, self RouterId, networkAddress IPv4, RouterIPAddress IPv4, RoutingTable *DVRTable, neighbours []chan<- interface{}, st *routerState) (IPv4, IPv4) {
+	conflict := RouterId(0)
+	found := false
+	for id, prefixes := range RoutingTable.AdvertisedPrefixes() {
+		for _, p := range prefixes {
+			if id < self && p.Overlaps(RouterIPAddress) && (!found || id < conflict) {
+				conflict, found = id, true
+			}
+		}
+	}
+	if !found {
+		return RouterIPAddress, networkAddress
+	}
+	block, ok := freeBlock(st.rng, supernetOf(st.cfg), RouterIPAddress.Prefix, takenBlocks(RoutingTable, self))
+	if !ok {
+		if logLevel != "none" {
+			log.Printf("[%v] Block %v overlaps that of [%v] and no block of its size is free in %v",
+				networkAddress.toString(false),
+				RouterIPAddress.toString(true),
+				conflict,
+				supernetOf(st.cfg).toString(true))
+		}
+		return RouterIPAddress, networkAddress
+	}
+	if logLevel != "none" {
+		log.Printf("[%v] Block %v overlaps that of [%v], moving to %v",
+			networkAddress.toString(false),
+			RouterIPAddress.toString(true),
+			conflict,
+			block.toString(true))
+	}
+	_, network := block.networkID()
+	RoutingTable.SetPrefixes(self, st.aliases.prefixes(block))
+	advertiseLinks(logLevel, self, network, block, RoutingTable, neighbours, st)
+	return block, network
+}
@@ -0,0 +1,88 @@
+package routers
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAllocateBlocks(t *testing.T) {
+	star := Template{{1, 2, 3}, {0}, {0}, {0}}
+	tests := []struct {
+		name     string
+		supernet IPv4
+		template Template
+		want     []IPv4
+		wantErr  bool
+	}{
+		{"no routers", IPv4{10, 0, 0, 0, 24}, Template{}, []IPv4{}, false},
+		{"lone router", IPv4{10, 0, 0, 0, 32}, Template{{}}, []IPv4{{10, 0, 0, 0, 32}}, false},
+		{
+			"largest first",
+			IPv4{10, 0, 0, 0, 24},
+			star,
+			[]IPv4{{10, 0, 0, 1, 30}, {10, 0, 0, 4, 31}, {10, 0, 0, 6, 31}, {10, 0, 0, 8, 31}},
+			false,
+		},
+		{
+			"equal blocks ordered by RouterId",
+			IPv4{10, 0, 0, 0, 24},
+			Template{{1}, {0, 2}, {1}},
+			[]IPv4{{10, 0, 0, 4, 31}, {10, 0, 0, 1, 30}, {10, 0, 0, 6, 31}},
+			false,
+		},
+		{
+			"host bits of the supernet ignored",
+			IPv4{172, 16, 5, 9, 16},
+			Template{{1}, {0}},
+			[]IPv4{{172, 16, 0, 0, 31}, {172, 16, 0, 2, 31}},
+			false,
+		},
+		{"supernet filled", IPv4{10, 0, 0, 0, 29}, star, nil, true},
+		{"block larger than supernet", IPv4{10, 0, 0, 0, 31}, star, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := AllocateBlocks(tt.supernet, tt.template)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("AllocateBlocks(%v) error = %v, want error %v", tt.supernet.toString(true), err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("AllocateBlocks(%v) = %v, want %v", tt.supernet.toString(true), got, tt.want)
+			}
+			again, _ := AllocateBlocks(tt.supernet, tt.template)
+			if !reflect.DeepEqual(got, again) {
+				t.Errorf("AllocateBlocks(%v) = %v then %v", tt.supernet.toString(true), got, again)
+			}
+		})
+	}
+}
+
+func TestAllocateBlocksAligned(t *testing.T) {
+	// Routers with 0 to 9 neighbours, so blocks of every size from /32 to /28
+	template := make(Template, 10)
+	for i := range template {
+		for j := range template {
+			if i != j && len(template[i]) < i {
+				template[i] = append(template[i], RouterId(j))
+			}
+		}
+	}
+	supernet := IPv4{10, 20, 0, 0, 24}
+	blocks, err := AllocateBlocks(supernet, template)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, b := range blocks {
+		if b.Prefix != blockPrefix(len(template[i])) {
+			t.Errorf("router %v with %v neighbours has block %v", i, len(template[i]), b.toString(true))
+		}
+		if b != blockAddress(maskTo(b.toUint32(), b.Prefix), b.Prefix) || !b.Overlaps(supernet) {
+			t.Errorf("block %v isn't aligned within %v", b.toString(true), supernet.toString(true))
+		}
+		for j := i + 1; j < len(blocks); j++ {
+			if b.Overlaps(blocks[j]) {
+				t.Errorf("blocks %v and %v of routers %v and %v overlap", b.toString(true), blocks[j].toString(true), i, j)
+			}
+		}
+	}
+}
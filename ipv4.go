@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"math"
 	"math/rand"
-	"time"
 )
 
 // ---- CLASSLESS IPv4 ----
@@ -19,18 +18,17 @@ type IPv4 struct {
 }
 
 // randomIPv4 ... Generate a random IPv4 with CIDR prefix of 0
-func randomIPv4() IPv4 {
-	return randomIPv4WithPrefix(0)
+func randomIPv4(rng *rand.Rand) IPv4 {
+	return randomIPv4WithPrefix(rng, 0)
 }
 
 // randomIPv4WithPrefix ... Generate a random IPv4 address with a given CIDR prefix
-func randomIPv4WithPrefix(prefix uint) IPv4 {
-	rand.Seed(time.Now().UnixNano())
+func randomIPv4WithPrefix(rng *rand.Rand, prefix uint) IPv4 {
 	return IPv4{
-		uint8(rand.Intn(256)),
-		uint8(rand.Intn(256)),
-		uint8(rand.Intn(256)),
-		uint8(rand.Intn(256)),
+		uint8(rng.Intn(256)),
+		uint8(rng.Intn(256)),
+		uint8(rng.Intn(256)),
+		uint8(rng.Intn(256)),
 		prefix,
 	}
 }
@@ -43,8 +41,8 @@ func ipCountToPrefix(ipCount int) uint {
 }
 
 // randomIPv4FromSubnetSize ... Given a subnet size, generate a random IPv4 address
-func randomIPv4FromSubetSize(subnetSize int) IPv4 {
-	return randomIPv4WithPrefix(ipCountToPrefix(subnetSize))
+func randomIPv4FromSubetSize(rng *rand.Rand, subnetSize int) IPv4 {
+	return randomIPv4WithPrefix(rng, ipCountToPrefix(subnetSize))
 }
 
 // toString ... Convert the IPv4 address to a string, conditionally showing CIDR prefix
@@ -55,6 +53,34 @@ func (ip IPv4) toString(printPrefix bool) string {
 	return fmt.Sprintf("%v.%v.%v.%v", ip.Quad1, ip.Quad2, ip.Quad3, ip.Quad4)
 }
 
+// String ... The IPv4 address in CIDR notation
+func (ip IPv4) String() string {
+	return ip.toString(true)
+}
+
+// ParseIPv4 ... Parse an address in CIDR notation, a.b.c.d/prefix
+func ParseIPv4(s string) (IPv4, error) {
+	var q [4]uint8
+	var prefix uint
+	if n, err := fmt.Sscanf(s, "%d.%d.%d.%d/%d", &q[0], &q[1], &q[2], &q[3], &prefix); err != nil || n != 5 {
+		return IPv4{}, fmt.Errorf("invalid CIDR block %q (expected a.b.c.d/prefix)", s)
+	}
+	if prefix > 32 {
+		return IPv4{}, fmt.Errorf("invalid CIDR block %q (prefix longer than 32 bits)", s)
+	}
+	return IPv4{q[0], q[1], q[2], q[3], prefix}, nil
+}
+
+// toUint32 ... The address as a 32 bit number, Quad1 most significant
+func (ip IPv4) toUint32() uint32 {
+	return uint32(ip.Quad1)<<24 | uint32(ip.Quad2)<<16 | uint32(ip.Quad3)<<8 | uint32(ip.Quad4)
+}
+
+// ipv4FromUint32 ... The address of a 32 bit number, with the given CIDR prefix
+func ipv4FromUint32(addr uint32, prefix uint) IPv4 {
+	return IPv4{uint8(addr >> 24), uint8(addr >> 16), uint8(addr >> 8), uint8(addr), prefix}
+}
+
 // addressCountForSubnet ... Calculate the amount of addresses in the provided subnet
 func addressCountForSubnet(subnet uint) float64 {
 	return math.Pow(2, float64(32-subnet))
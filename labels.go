@@ -0,0 +1,362 @@
+package routers
+
+import (
+	"fmt"
+	"log"
+	"reflect"
+	"time"
+)
+
+// firstLabel ... Lowest label a router allocates, those below are reserved as in MPLS
+const firstLabel = 16
+
+// labelRetry ... Time an ingress waits for a label mapping before requesting the path again
+const labelRetry = time.Second
+
+// LabelRequest ... Sent hop by hop along Path from its first router (the ingress) to its last (the egress), asking
+// each to take part in a label switched path. At each of the Waypoints (indexes into Path) the path is split in
+// two, the waypoint ending the outer path and starting a tunnel on to the egress
+type LabelRequest struct {
+	Path      Path
+	Hop       int // Index in Path of the receiving router
+	Waypoints []int
+	FEC       string // The ingress's forwarding equivalence class for the path
+}
+
+// LabelMapping ... Returned hop by hop from the egress towards the ingress, carrying the label the sender
+// (Path[Hop+1]) expects for the path and the binding labels of the waypoints beyond it, bottom of stack first
+type LabelMapping struct {
+	Path      Path
+	Hop       int // Index in Path of the receiving router
+	Waypoints []int
+	FEC       string
+	Label     uint32
+	Inner     []uint32
+}
+
+// LabelWithdraw ... Sent down a label switched path by its ingress once the path is out of date, carrying the labels
+// the ingress pushed. Each router releases the labels it allocated, popping and swapping them as it would for an
+// envelope, and passes the rest on
+type LabelWithdraw struct {
+	Labels []uint32
+}
+
+// labelEntry ... What a router does with an envelope whose top label it allocated
+type labelEntry struct {
+	Pop       bool   // This router ends the path, remove the label and carry on with whatever lies beneath
+	Out       uint32 // Label swapped in before sending the envelope on to Neighbour
+	Neighbour RouterId
+}
+
+// ingressEntry ... Labels pushed onto envelopes of a forwarding equivalence class by the ingress, top last, and
+// the path they were bound along
+type ingressEntry struct {
+	Labels    []uint32
+	Neighbour RouterId
+	Path      Path
+	Waypoints []int
+	Version   uint64 // Version of the ingress's routing table the path was computed from
+}
+
+// pendingPath ... A label request awaiting its mapping
+type pendingPath struct {
+	requested time.Time
+	version   uint64 // Version of the ingress's routing table the path was computed from
+}
+
+// ForwardingCounters ... How a router made its forwarding decisions and the time it spent making them
+type ForwardingCounters struct {
+	LabelSwitched uint64        `json:"label_switched"` // Envelopes forwarded by label
+	LabelTime     time.Duration `json:"label_time_ns"`  // Total time spent looking up labels
+	Routed        uint64        `json:"routed"`         // Envelopes forwarded by looking up the routing table
+	RouteTime     time.Duration `json:"route_time_ns"`  // Total time spent looking up routes
+	Labels        int           `json:"labels"`         // Labels allocated by this router
+}
+
+// labelTable ... A router's label switching state, the labels it allocated (incoming label map), the labels it
+// pushes as an ingress and the requests still awaiting a mapping
+type labelTable struct {
+	enabled  bool
+	next     uint32
+	incoming map[uint32]labelEntry
+	ingress  map[string]ingressEntry
+	pending  map[string]pendingPath
+	counters ForwardingCounters
+}
+
+func newLabelTable(cfg Config) *labelTable {
+	return &labelTable{
+		enabled:  cfg.LabelSwitching,
+		next:     firstLabel,
+		incoming: make(map[uint32]labelEntry),
+		ingress:  make(map[string]ingressEntry),
+		pending:  make(map[string]pendingPath),
+	}
+}
+
+// allocate ... Install the entry under a fresh label
+func (l *labelTable) allocate(entry labelEntry) uint32 {
+	label := l.next
+	l.next++
+	l.incoming[label] = entry
+	l.counters.Labels++
+	return label
+}
+
+// linkDown ... Forget the labels and ingress paths leading over the failed link to {neighbour}, leaving their
+// envelopes to be routed by table
+func (l *labelTable) linkDown(neighbour RouterId) {
+	for label, entry := range l.incoming {
+		if !entry.Pop && entry.Neighbour == neighbour {
+			delete(l.incoming, label)
+		}
+	}
+	for class, entry := range l.ingress {
+		if entry.Neighbour == neighbour {
+			delete(l.ingress, class)
+		}
+	}
+}
+
+// fec ... Forwarding equivalence class of the envelope, its destination and source route
+func fec(msg Envelope) string {
+	if len(msg.Segments) == 0 {
+		return fmt.Sprint(msg.Dest)
+	}
+	return fmt.Sprint(msg.Dest, msg.Segments, msg.Strict)
+}
+
+// switchLabels ... Forward the envelope by its top label, popping any labels that end here. Reports false once no
+// labels remain, or should they be unusable, leaving the envelope to be delivered or routed as normal
+func switchLabels(logLevel string, msg *Envelope, self RouterId, networkAddress IPv4, neighbours []chan<- interface{}, NMap NeighbourMap, raw interface{}, st *routerState) bool {
+	start := time.Now()
+	for len(msg.Labels) > 0 {
+		top := msg.Labels[len(msg.Labels)-1]
+		entry, ok := st.labels.incoming[top]
+		if !ok {
+			if logLevel != "none" {
+				log.Printf("[%v] Unknown label %v, routing envelope for [%v] by table",
+					networkAddress.toString(false),
+					top,
+					msg.Dest)
+			}
+			msg.Labels = nil
+			break
+		}
+		if entry.Pop {
+			msg.Labels = msg.Labels[:len(msg.Labels)-1]
+			continue
+		}
+		if !(hopContext{self, NMap, st.balance, st.fib}).usable(entry.Neighbour) {
+			if logLevel != "none" {
+				log.Printf("[%v] Label %v leads to unusable neighbour [%v], routing envelope for [%v] by table",
+					networkAddress.toString(false),
+					top,
+					entry.Neighbour,
+					msg.Dest)
+			}
+			msg.Labels = nil
+			break
+		}
+		// Swap into a copy, the stack's backing array is shared with the envelope's earlier copies
+		swapped := append(make([]uint32, 0, len(msg.Labels)), msg.Labels...)
+		swapped[len(swapped)-1] = entry.Out
+		msg.Labels = swapped
+		msg.Hops++
+		st.labels.counters.LabelSwitched++
+		st.labels.counters.LabelTime += time.Since(start)
+		if logLevel != "none" {
+			log.Printf("[%v] Swapping label %v for %v towards [%v]",
+				networkAddress.toString(false),
+				top,
+				entry.Out,
+				entry.Neighbour)
+		}
+		sendEnvelope(logLevel, *msg, entry.Neighbour, self, networkAddress, neighbours, NMap, raw, st)
+		return true
+	}
+	st.labels.counters.LabelTime += time.Since(start)
+	return false
+}
+
+// pushLabels ... At the ingress, push the labels of the envelope's label switched path and send it on. When there
+// is no path yet, request one along the least cost route (through any waypoints) and report false. A path is kept
+// across changes to the routing table only while it remains the route to take, and is otherwise withdrawn
+func pushLabels(logLevel string, msg *Envelope, RoutingTable *DVRTable, self RouterId, networkAddress IPv4, neighbours []chan<- interface{}, NMap NeighbourMap, raw interface{}, st *routerState) bool {
+	class := fec(*msg)
+	version := RoutingTable.Version()
+	if entry, ok := st.labels.ingress[class]; ok && entry.Version != version {
+		path, waypoints, ok := labelPath(RoutingTable, self, *msg)
+		if ok && samePath(path, entry.Path) && reflect.DeepEqual(waypoints, entry.Waypoints) {
+			entry.Version = version
+			st.labels.ingress[class] = entry
+		} else {
+			withdrawPath(logLevel, class, self, networkAddress, neighbours, NMap, st)
+		}
+	}
+	if entry, ok := st.labels.ingress[class]; ok {
+		if (hopContext{self, NMap, st.balance, st.fib}).usable(entry.Neighbour) {
+			msg.Labels = append([]uint32(nil), entry.Labels...)
+			// The labels now carry the source route
+			msg.Segments, msg.Strict = nil, false
+			st.labels.counters.LabelSwitched++
+			if logLevel != "none" {
+				log.Printf("[%v] Pushing labels %v for [%v] towards [%v]",
+					networkAddress.toString(false),
+					msg.Labels,
+					class,
+					entry.Neighbour)
+			}
+			sendEnvelope(logLevel, *msg, entry.Neighbour, self, networkAddress, neighbours, NMap, raw, st)
+			return true
+		}
+		withdrawPath(logLevel, class, self, networkAddress, neighbours, NMap, st)
+	}
+	if p, ok := st.labels.pending[class]; ok && p.version == version && time.Since(p.requested) < labelRetry {
+		return false
+	}
+	path, waypoints, ok := labelPath(RoutingTable, self, *msg)
+	if !ok || len(path) < 2 {
+		return false
+	}
+	st.labels.pending[class] = pendingPath{requested: time.Now(), version: version}
+	if logLevel != "none" {
+		log.Printf("[%v] Requesting label switched path %v for [%v]",
+			networkAddress.toString(false),
+			path,
+			class)
+	}
+	sendLabelMessage(logLevel, LabelRequest{Path: path, Hop: 1, Waypoints: waypoints, FEC: class}, path[1], networkAddress, neighbours, NMap, st.cfg.Done)
+	return false
+}
+
+// withdrawPath ... Forget the ingress's path for {class}, sending its labels down it to be released if the first
+// hop can still be reached
+func withdrawPath(logLevel string, class string, self RouterId, networkAddress IPv4, neighbours []chan<- interface{}, NMap NeighbourMap, st *routerState) {
+	entry := st.labels.ingress[class]
+	delete(st.labels.ingress, class)
+	if !(hopContext{self, NMap, st.balance, st.fib}).usable(entry.Neighbour) {
+		return
+	}
+	if logLevel != "none" {
+		log.Printf("[%v] Withdrawing label switched path %v for [%v]",
+			networkAddress.toString(false),
+			entry.Path,
+			class)
+	}
+	sendLabelMessage(logLevel, LabelWithdraw{Labels: entry.Labels}, entry.Neighbour, networkAddress, neighbours, NMap, st.cfg.Done)
+}
+
+// labelPath ... Route for the envelope's label switched path, the strict source route as given or else the least
+// cost paths through each waypoint in turn, alongside the indexes of the waypoints
+func labelPath(table *DVRTable, self RouterId, msg Envelope) (Path, []int, bool) {
+	if msg.Strict {
+		return append(append(Path{self}, msg.Segments...), msg.Dest), nil, true
+	}
+	path := Path{self}
+	waypoints := make([]int, 0, len(msg.Segments))
+	for i, target := range append(append([]RouterId(nil), msg.Segments...), msg.Dest) {
+		from := path[len(path)-1]
+		if from == target {
+			continue
+		}
+		leg, _, ok := constrainedShortestPath(table, from, target, nil, nil)
+		if !ok {
+			return nil, nil, false
+		}
+		path = append(path, leg[1:]...)
+		if i < len(msg.Segments) {
+			waypoints = append(waypoints, len(path)-1)
+		}
+	}
+	return path, waypoints, true
+}
+
+// sendLabelMessage ... Send a label request or mapping to the neighbour {to}, if its channel is known
+func sendLabelMessage(logLevel string, msg interface{}, to RouterId, networkAddress IPv4, neighbours []chan<- interface{}, NMap NeighbourMap, done <-chan struct{}) {
+	index, ok := NMap[to]
+	if !ok {
+		if logLevel != "none" {
+			log.Printf("[%v] No channel to [%v] for label distribution, abandoning the path",
+				networkAddress.toString(false),
+				to)
+		}
+		return
+	}
+	go sendOrStop(neighbours[index], msg, done)
+}
+
+// processLabelRequest ... Pass the request on towards the egress, which allocates the path's last label
+func processLabelRequest(logLevel string, msg LabelRequest, networkAddress IPv4, neighbours []chan<- interface{}, NMap NeighbourMap, st *routerState) {
+	if msg.Hop < len(msg.Path)-1 {
+		msg.Hop++
+		sendLabelMessage(logLevel, msg, msg.Path[msg.Hop], networkAddress, neighbours, NMap, st.cfg.Done)
+		return
+	}
+	mapping := LabelMapping{
+		Path:      msg.Path,
+		Hop:       msg.Hop - 1,
+		Waypoints: msg.Waypoints,
+		FEC:       msg.FEC,
+		Label:     st.labels.allocate(labelEntry{Pop: true}),
+	}
+	sendLabelMessage(logLevel, mapping, mapping.Path[mapping.Hop], networkAddress, neighbours, NMap, st.cfg.Done)
+}
+
+// processLabelMapping ... Allocate this router's label for the path, swapping to the label of the router after it,
+// and pass it back towards the ingress. A waypoint instead ends the outer path, its binding label for the tunnel on
+// joining the inner labels. The ingress installs the labels it will push
+func processLabelMapping(logLevel string, msg LabelMapping, networkAddress IPv4, neighbours []chan<- interface{}, NMap NeighbourMap, st *routerState) {
+	downstream := msg.Path[msg.Hop+1]
+	if msg.Hop == 0 {
+		requested := st.labels.pending[msg.FEC]
+		delete(st.labels.pending, msg.FEC)
+		st.labels.ingress[msg.FEC] = ingressEntry{
+			Labels:    append(append([]uint32(nil), msg.Inner...), msg.Label),
+			Neighbour: downstream,
+			Path:      msg.Path,
+			Waypoints: msg.Waypoints,
+			Version:   requested.version,
+		}
+		if logLevel != "none" {
+			log.Printf("[%v] Label switched path %v for [%v] is up",
+				networkAddress.toString(false),
+				msg.Path,
+				msg.FEC)
+		}
+		return
+	}
+	label := st.labels.allocate(labelEntry{Out: msg.Label, Neighbour: downstream})
+	for _, w := range msg.Waypoints {
+		if w == msg.Hop {
+			msg.Inner = append(append([]uint32(nil), msg.Inner...), label)
+			label = st.labels.allocate(labelEntry{Pop: true})
+			break
+		}
+	}
+	msg.Label = label
+	msg.Hop--
+	sendLabelMessage(logLevel, msg, msg.Path[msg.Hop], networkAddress, neighbours, NMap, st.cfg.Done)
+}
+
+// processLabelWithdraw ... Release the labels this router allocated for a withdrawn path, popping those that end
+// here and passing the path's remaining labels on to the router after it
+func processLabelWithdraw(logLevel string, msg LabelWithdraw, networkAddress IPv4, neighbours []chan<- interface{}, NMap NeighbourMap, st *routerState) {
+	for len(msg.Labels) > 0 {
+		top := msg.Labels[len(msg.Labels)-1]
+		entry, ok := st.labels.incoming[top]
+		if !ok {
+			return
+		}
+		delete(st.labels.incoming, top)
+		if entry.Pop {
+			msg.Labels = msg.Labels[:len(msg.Labels)-1]
+			continue
+		}
+		swapped := append(make([]uint32, 0, len(msg.Labels)), msg.Labels...)
+		swapped[len(swapped)-1] = entry.Out
+		msg.Labels = swapped
+		sendLabelMessage(logLevel, msg, entry.Neighbour, networkAddress, neighbours, NMap, st.cfg.Done)
+		return
+	}
+}
@@ -0,0 +1,159 @@
+package routers
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLabelPath(t *testing.T) {
+	tests := []struct {
+		name      string
+		msg       Envelope
+		want      Path
+		waypoints []int
+		ok        bool
+	}{
+		{"least cost", Envelope{Dest: 5}, Path{0, 1, 2, 5}, []int{}, true},
+		{"strict", Envelope{Dest: 5, Segments: []RouterId{3, 4}, Strict: true}, Path{0, 3, 4, 5}, nil, true},
+		{"waypoint", Envelope{Dest: 2, Segments: []RouterId{4}}, Path{0, 1, 4, 1, 2}, []int{2}, true},
+		{"waypoints", Envelope{Dest: 0, Segments: []RouterId{2, 3}}, Path{0, 1, 2, 1, 0, 3, 0}, []int{2, 5}, true},
+		{"waypoint at the source", Envelope{Dest: 1, Segments: []RouterId{0}}, Path{0, 1}, []int{}, true},
+		{"unreachable", Envelope{Dest: 9}, nil, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, waypoints, ok := labelPath(TableFromTemplate(ladder.Template, nil), 0, tt.msg)
+			if !reflect.DeepEqual(path, tt.want) || !reflect.DeepEqual(waypoints, tt.waypoints) || ok != tt.ok {
+				t.Errorf("labelPath(%v via %v) = %v, %v, %v, want %v, %v, %v", tt.msg.Dest, tt.msg.Segments, path, waypoints, ok, tt.want, tt.waypoints, tt.ok)
+			}
+		})
+	}
+}
+
+func TestLabelSwitching(t *testing.T) {
+	tests := []struct {
+		name   string
+		msg    Envelope
+		want   Path
+		labels int // Labels the ingress pushes
+	}{
+		{"least cost", Envelope{Dest: 5}, Path{0, 1, 2, 5}, 1},
+		{"strict", Envelope{Dest: 5, Segments: []RouterId{3, 4}, Strict: true}, Path{0, 3, 4, 5}, 1},
+		{"tunnel through a waypoint", Envelope{Dest: 2, Segments: []RouterId{4}}, Path{0, 1, 4, 1, 2}, 2},
+		{"tunnels through waypoints", Envelope{Dest: 0, Segments: []RouterId{2, 3}}, Path{0, 1, 2, 1, 0, 3, 0}, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := newTestNetwork(ladder, Config{LabelSwitching: true})
+			// The first envelope is routed by table while its path is set up
+			if _, delivered := n.inject(0, tt.msg); len(delivered) != 1 {
+				t.Fatalf("delivered %+v setting up the path, want one envelope", delivered)
+			}
+			entry, ok := n.states[0].labels.ingress[fec(tt.msg)]
+			if !ok || len(entry.Labels) != tt.labels {
+				t.Fatalf("ingress entry %+v, want %v labels", entry, tt.labels)
+			}
+			switched := make([]uint64, len(n.states))
+			for i, st := range n.states {
+				switched[i] = st.labels.counters.LabelSwitched
+			}
+			visited, delivered := n.inject(0, tt.msg)
+			if !reflect.DeepEqual(visited, tt.want) {
+				t.Errorf("label switched %v, want %v", visited, tt.want)
+			}
+			if len(delivered) != 1 || delivered[0].Dest != tt.msg.Dest || len(delivered[0].Labels) != 0 {
+				t.Errorf("delivered %+v, want one envelope at [%v] without labels", delivered, tt.msg.Dest)
+			}
+			for i, id := range tt.want[:len(tt.want)-1] {
+				if n.states[id].labels.counters.LabelSwitched == switched[id] {
+					t.Errorf("hop %v at [%v] wasn't label switched", i, id)
+				}
+			}
+		})
+	}
+}
+
+func TestLabelSwitchingFallback(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(n *testNetwork) // Run once the path has been set up
+		want  Path
+	}{
+		{"path up", func(n *testNetwork) {}, Path{0, 1, 2, 5}},
+		{"unknown label", func(n *testNetwork) {
+			n.states[1].labels.incoming = make(map[uint32]labelEntry)
+		}, Path{0, 1, 2, 5}},
+		{"next hop down", func(n *testNetwork) {
+			n.states[1].fib.fail(2)
+		}, Path{0, 1, 4, 5}},
+		{"first hop down", func(n *testNetwork) {
+			n.states[0].fib.fail(1)
+		}, Path{0, 3, 4, 5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := newTestNetwork(ladder, Config{LabelSwitching: true})
+			n.inject(0, Envelope{Dest: 5})
+			tt.setup(n)
+			visited, delivered := n.inject(0, Envelope{Dest: 5})
+			if !reflect.DeepEqual(visited, tt.want) {
+				t.Errorf("routed %v, want %v", visited, tt.want)
+			}
+			if len(delivered) != 1 || delivered[0].Dest != 5 {
+				t.Errorf("delivered %+v, want one envelope at [5]", delivered)
+			}
+		})
+	}
+}
+
+func TestLabelSwitchingExpiry(t *testing.T) {
+	tests := []struct {
+		name     string
+		setup    func(n *testNetwork) // Run once the path 0-1-2-5 has been set up
+		released []RouterId           // Routers of the old path that no longer hold its labels
+		bound    Path                 // Path the ingress holds labels for afterwards
+	}{
+		{"table unchanged", func(n *testNetwork) {}, nil, Path{0, 1, 2, 5}},
+		{"unrelated change", func(n *testNetwork) {
+			n.tables[0].RemoveLink(3, 4)
+			n.tables[0].RemoveLink(4, 3)
+		}, nil, Path{0, 1, 2, 5}},
+		{"path no longer least cost", func(n *testNetwork) {
+			n.tables[0].RemoveLink(1, 2)
+			n.tables[0].RemoveLink(2, 1)
+		}, []RouterId{1, 2, 5}, Path{0, 1, 4, 5}},
+		{"link down at the ingress", func(n *testNetwork) {
+			n.handle(0, LinkDown{ID: 1})
+		}, nil, Path{0, 3, 4, 5}},
+		{"link down on the path", func(n *testNetwork) {
+			n.handle(1, LinkDown{ID: 2})
+		}, []RouterId{1}, Path{0, 1, 2, 5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := newTestNetwork(ladder, Config{LabelSwitching: true})
+			n.inject(0, Envelope{Dest: 5})
+			old := make(map[RouterId][]uint32)
+			for _, id := range []RouterId{1, 2, 5} {
+				for label := range n.states[id].labels.incoming {
+					old[id] = append(old[id], label)
+				}
+			}
+			tt.setup(n)
+			if _, delivered := n.inject(0, Envelope{Dest: 5}); len(delivered) != 1 {
+				t.Fatalf("delivered %+v, want one envelope", delivered)
+			}
+			for id, labels := range old {
+				want := !hasLink(tt.released, id)
+				for _, label := range labels {
+					if _, held := n.states[id].labels.incoming[label]; held != want {
+						t.Errorf("[%v] holds label %v = %v, want %v", id, label, held, want)
+					}
+				}
+			}
+			if entry, ok := n.states[0].labels.ingress[fec(Envelope{Dest: 5})]; !ok || !samePath(entry.Path, tt.bound) {
+				t.Errorf("ingress entry %+v, want labels for %v", entry, tt.bound)
+			}
+		})
+	}
+}
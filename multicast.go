@@ -0,0 +1,181 @@
+package routers
+
+import (
+	"log"
+	"time"
+)
+
+// GroupId ... Multicast group, numbered from 1
+type GroupId uint32
+
+// Join ... Make the receiving router a member of the multicast group
+type Join struct {
+	Group GroupId
+}
+
+// Leave ... Remove the receiving router from the multicast group
+type Leave struct {
+	Group GroupId
+}
+
+// sourceTrees ... Least cost trees rooted at each source, as learned in the routing table, recomputed whenever the
+// table changes
+type sourceTrees struct {
+	version uint64
+	costs   map[RouterId]map[RouterId]float64
+	parents map[RouterId]map[RouterId]RouterId
+}
+
+// tree ... Least cost from {source} to each router it can reach, and the router's parent on the tree rooted there
+func (t *sourceTrees) tree(table *DVRTable, source RouterId) (map[RouterId]float64, map[RouterId]RouterId) {
+	if version := table.Version(); t.parents == nil || version != t.version {
+		t.version = version
+		t.costs = make(map[RouterId]map[RouterId]float64)
+		t.parents = make(map[RouterId]map[RouterId]RouterId)
+	}
+	if _, ok := t.parents[source]; !ok {
+		t.costs[source], t.parents[source] = shortestPaths(table, source)
+	}
+	return t.costs[source], t.parents[source]
+}
+
+func containsGroup(groups []GroupId, group GroupId) bool {
+	for _, g := range groups {
+		if g == group {
+			return true
+		}
+	}
+	return false
+}
+
+// processMembership ... Join or leave the group and advertise the change along with this router's links
+func processMembership(logLevel string, group GroupId, join bool, self RouterId, networkAddress IPv4, RouterIPAddress IPv4, RoutingTable *DVRTable, neighbours []chan<- interface{}, st *routerState) {
+	groups := RoutingTable.Groups(self)
+	if containsGroup(groups, group) == join {
+		return
+	}
+	if join {
+		groups = append(groups, group)
+	} else {
+		kept := groups[:0]
+		for _, g := range groups {
+			if g != group {
+				kept = append(kept, g)
+			}
+		}
+		groups = kept
+	}
+	RoutingTable.SetGroups(self, groups)
+	if logLevel != "none" {
+		action := "Leaving"
+		if join {
+			action = "Joining"
+		}
+		log.Printf("[%v] %v multicast group %v", networkAddress.toString(false), action, group)
+	}
+	advertiseLinks(logLevel, self, networkAddress, RouterIPAddress, RoutingTable, neighbours, st)
+}
+
+// processGroupEnvelope ... Deliver a broadcast or multicast envelope here if this router is a receiver, and send a
+// copy to each child on the least cost tree rooted at the router it was injected at. Multicast trees are pruned to
+// the branches leading to members. Copies that didn't arrive from this router's parent on the tree fail the reverse
+// path check and are dropped, so no router receives the envelope twice
+func processGroupEnvelope(logLevel string, msg Envelope, self RouterId, framework chan<- Envelope, networkAddress IPv4, RouterIPAddress IPv4, raw interface{}, RoutingTable *DVRTable, neighbours []chan<- interface{}, NMap NeighbourMap, st *routerState) {
+	if msg.Hops == 0 {
+		msg.Source = self
+		if msg.Broadcast && logLevel != "none" {
+			_, broadcast := RouterIPAddress.broadcastID()
+			log.Printf("[%v] Broadcasting envelope from block broadcast address %v",
+				networkAddress.toString(false),
+				broadcast.toString(true))
+		}
+	}
+	_, parents := st.trees.tree(RoutingTable, msg.Source)
+	if msg.Source != self {
+		if parent, ok := parents[self]; !ok || parent != msg.previous {
+			if logLevel != "none" {
+				log.Printf("[%v] Dropping envelope from [%v] via [%v], not on the reverse path",
+					networkAddress.toString(false),
+					msg.Source,
+					msg.previous)
+			}
+			return
+		}
+	}
+	if msg.Broadcast || containsGroup(RoutingTable.Groups(self), msg.Group) {
+		delivered := msg
+		delivered.Dest = self
+		delivered.Delivered = time.Now()
+		if logLevel != "none" {
+			log.Printf("| << [%v] {Envelope: %v} --DELIVERED-- HOPS: %v",
+				networkAddress.toString(false),
+				&raw,
+				msg.Hops)
+		}
+		select {
+		case framework <- delivered:
+		case <-st.cfg.Done:
+		}
+	}
+	var onTree map[RouterId]bool
+	if !msg.Broadcast {
+		// Mark every router on the paths from the source to the members
+		onTree = make(map[RouterId]bool)
+		for _, member := range RoutingTable.Members(msg.Group) {
+			for id := member; !onTree[id]; {
+				onTree[id] = true
+				parent, ok := parents[id]
+				if !ok {
+					break
+				}
+				id = parent
+			}
+		}
+	}
+	ctx := hopContext{self, NMap, st.balance, st.fib}
+	for _, n := range RoutingTable.Neighbours(self) {
+		if parent, ok := parents[n]; !ok || parent != self || n == msg.Source {
+			continue
+		}
+		if (msg.Broadcast || onTree[n]) && ctx.usable(n) {
+			copied := msg
+			copied.Hops++
+			sendEnvelope(logLevel, copied, n, self, networkAddress, neighbours, NMap, raw, st)
+		}
+	}
+}
+
+// nearestOf ... The reachable candidate (in ascending order) of least cost, the lowest RouterId among equals
+func nearestOf(costs map[RouterId]float64, candidates []RouterId) (RouterId, bool) {
+	nearest, found := RouterId(0), false
+	for _, c := range candidates {
+		if cost, ok := costs[c]; ok && (!found || cost < costs[nearest]-costEpsilon) {
+			nearest, found = c, true
+		}
+	}
+	return nearest, found
+}
+
+// resolveAnycast ... Address the envelope to the nearest member of its anycast group by least cost from here, the
+// lowest RouterId among equals. Every router on the way resolves it again, so the envelope fails over to the next
+// nearest member as soon as the table loses the way to one. Without a reachable member Dest is left as it is
+func resolveAnycast(logLevel string, msg *Envelope, self RouterId, networkAddress IPv4, RoutingTable *DVRTable, trees *sourceTrees) {
+	costs, _ := trees.tree(RoutingTable, self)
+	nearest, found := nearestOf(costs, RoutingTable.Members(msg.Anycast))
+	if !found {
+		if logLevel != "none" {
+			log.Printf("[%v] No reachable member of anycast group %v, routing to [%v]",
+				networkAddress.toString(false),
+				msg.Anycast,
+				msg.Dest)
+		}
+		return
+	}
+	if nearest != msg.Dest && logLevel != "none" {
+		log.Printf("[%v] Nearest member of anycast group %v is [%v]",
+			networkAddress.toString(false),
+			msg.Anycast,
+			nearest)
+	}
+	msg.Dest = nearest
+}
@@ -0,0 +1,130 @@
+package routers
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// joinAll ... Make {members} members of the group in every router's table, as their advertisements would
+func (n *testNetwork) joinAll(group GroupId, members []RouterId) {
+	for _, table := range n.tables {
+		for _, m := range members {
+			table.SetGroups(m, append(table.Groups(m), group))
+		}
+	}
+}
+
+// deliveredTo ... Routers the envelopes were delivered to, in ascending order
+func deliveredTo(delivered []Envelope) []RouterId {
+	ids := make([]RouterId, len(delivered))
+	for i, msg := range delivered {
+		ids[i] = msg.Dest
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+func TestGroupDelivery(t *testing.T) {
+	// The tree rooted at 0 in the ladder is 0 -> 1, 3 and 1 -> 2, 4 and 2 -> 5, equal costs broken towards the
+	// lowest RouterId
+	tests := []struct {
+		name      string
+		broadcast bool
+		members   []RouterId
+		visited   Path
+		delivered []RouterId
+	}{
+		{"broadcast", true, nil, Path{0, 1, 3, 2, 4, 5}, []RouterId{0, 1, 2, 3, 4, 5}},
+		{"single member", false, []RouterId{5}, Path{0, 1, 2, 5}, []RouterId{5}},
+		{"pruned to members", false, []RouterId{3, 5}, Path{0, 1, 3, 2, 5}, []RouterId{3, 5}},
+		{"source a member", false, []RouterId{0, 4}, Path{0, 1, 4}, []RouterId{0, 4}},
+		{"no members", false, nil, Path{0}, []RouterId{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := newTestNetwork(ladder, Config{})
+			n.joinAll(1, tt.members)
+			msg := Envelope{Broadcast: tt.broadcast}
+			if !tt.broadcast {
+				msg.Group = 1
+			}
+			visited, delivered := n.inject(0, msg)
+			if !reflect.DeepEqual(visited, tt.visited) {
+				t.Errorf("visited %v, want %v", visited, tt.visited)
+			}
+			if got := deliveredTo(delivered); !reflect.DeepEqual(got, tt.delivered) {
+				t.Errorf("delivered to %v, want %v", got, tt.delivered)
+			}
+			for _, msg := range delivered {
+				if msg.Source != 0 {
+					t.Errorf("copy delivered to [%v] has source [%v], want [0]", msg.Dest, msg.Source)
+				}
+			}
+		})
+	}
+}
+
+func TestReversePathCheck(t *testing.T) {
+	tests := []struct {
+		name      string
+		at        RouterId
+		previous  RouterId
+		delivered []RouterId
+		sent      int
+	}{
+		{"from the parent", 1, 0, []RouterId{1}, 2},
+		{"from a child", 1, 2, []RouterId{}, 0},
+		{"leaf from the parent", 4, 1, []RouterId{4}, 0},
+		{"leaf not from the parent", 4, 3, []RouterId{}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := newTestNetwork(ladder, Config{})
+			sent := n.handle(tt.at, Envelope{Source: 0, Broadcast: true, Hops: 1, previous: tt.previous})
+			delivered := make([]Envelope, 0)
+			for len(n.framework) > 0 {
+				delivered = append(delivered, <-n.framework)
+			}
+			if got := deliveredTo(delivered); !reflect.DeepEqual(got, tt.delivered) || len(sent) != tt.sent {
+				t.Errorf("delivered to %v and sent %v, want %v and %v sent", got, sent, tt.delivered, tt.sent)
+			}
+		})
+	}
+}
+
+func TestAnycast(t *testing.T) {
+	tests := []struct {
+		name    string
+		members []RouterId
+		removed []RouterId // Routers every table has lost the way to
+		dest    RouterId   // Where the envelope is addressed should no member be reachable
+		want    Path
+	}{
+		{"nearest member", []RouterId{3, 5}, nil, 0, Path{0, 3}},
+		{"lowest RouterId among equals", []RouterId{4, 2}, nil, 0, Path{0, 1, 2}},
+		{"member at the source", []RouterId{0, 1}, nil, 5, Path{0}},
+		{"fails over", []RouterId{3, 5}, []RouterId{3}, 0, Path{0, 1, 2, 5}},
+		{"no reachable member", []RouterId{3}, []RouterId{3}, 4, Path{0, 1, 4}},
+		{"no members", nil, nil, 2, Path{0, 1, 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := newTestNetwork(ladder, Config{})
+			n.joinAll(2, tt.members)
+			for _, table := range n.tables {
+				for _, id := range tt.removed {
+					table.RemoveRouter(id)
+					table.SetGroups(id, []GroupId{2})
+				}
+			}
+			visited, delivered := n.inject(0, Envelope{Dest: tt.dest, Anycast: 2})
+			if !reflect.DeepEqual(visited, tt.want) {
+				t.Errorf("routed %v, want %v", visited, tt.want)
+			}
+			if last := tt.want[len(tt.want)-1]; len(delivered) != 1 || delivered[0].Dest != last {
+				t.Errorf("delivered %+v, want one envelope at [%v]", delivered, last)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package routers
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"sync/atomic"
+)
+
+// multipathModes ... Supported values of Config.Multipath
+var multipathModes = []string{"", "none", "flow", "packet"}
+
+// validMultipath ... Check the multipath mode is supported
+func validMultipath(mode string) error {
+	for _, m := range multipathModes {
+		if m == mode {
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported multipath mode %q (expected none, flow or packet)", mode)
+}
+
+// balancer ... A router's load spreading state, the round-robin position for each destination, the number of
+// envelopes sent over each outgoing link and the number still waiting to be taken by each neighbour
+type balancer struct {
+	mode        string
+	next        map[RouterId]int
+	utilisation map[RouterId]uint64
+	queued      map[RouterId]*int64
+}
+
+func newBalancer(mode string) *balancer {
+	return &balancer{
+		mode:        mode,
+		next:        make(map[RouterId]int),
+		utilisation: make(map[RouterId]uint64),
+		queued:      make(map[RouterId]*int64),
+	}
+}
+
+// choose ... Pick the next hop for the envelope from the equal cost candidates (in ascending order).
+// Without multipath the lowest RouterId is always chosen
+func (b *balancer) choose(self RouterId, msg Envelope, candidates []RouterId) RouterId {
+	switch b.mode {
+	case "flow":
+		return candidates[flowHash(self, msg)%uint32(len(candidates))]
+	case "packet":
+		i := b.next[msg.Dest] % len(candidates)
+		b.next[msg.Dest] = i + 1
+		return candidates[i]
+	default:
+		return candidates[0]
+	}
+}
+
+// sent ... Count an envelope forwarded over the link to {neighbour}
+func (b *balancer) sent(neighbour RouterId) {
+	b.utilisation[neighbour]++
+}
+
+// queue ... Count an envelope waiting to be taken by {neighbour}, returning the counter for the sender to decrement
+func (b *balancer) queue(neighbour RouterId) *int64 {
+	q, ok := b.queued[neighbour]
+	if !ok {
+		q = new(int64)
+		b.queued[neighbour] = q
+	}
+	atomic.AddInt64(q, 1)
+	return q
+}
+
+// load ... Envelopes still waiting to be taken by {neighbour}
+func (b *balancer) load(neighbour RouterId) int64 {
+	if q, ok := b.queued[neighbour]; ok {
+		return atomic.LoadInt64(q)
+	}
+	return 0
+}
+
+// flowHash ... Hash of the envelope's flow (source, destination, flow label). The router's own ID is mixed in so
+// that consecutive routers don't all make the same choice among their candidates
+func flowHash(self RouterId, msg Envelope) uint32 {
+	h := fnv.New32a()
+	var buf [8]byte
+	for _, v := range []uint64{uint64(self), uint64(msg.Source), uint64(msg.Dest), uint64(msg.FlowLabel)} {
+		binary.LittleEndian.PutUint64(buf[:], v)
+		h.Write(buf[:])
+	}
+	return h.Sum32()
+}
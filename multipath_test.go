@@ -0,0 +1,86 @@
+package routers
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEqualCostNextHops(t *testing.T) {
+	// Ring 0-1-3-2-0 with a diagonal between 0 and 3
+	ring := Template{{1, 2, 3}, {0, 3}, {0, 3}, {1, 2, 0}}
+	tests := []struct {
+		name     string
+		template Template
+		costs    Costs
+		start    RouterId
+		end      RouterId
+		want     []RouterId
+		cost     float64
+	}{
+		{"neighbour", ring, Costs{{0, 3}: 5, {3, 0}: 5}, 0, 1, []RouterId{1}, 1},
+		{"two ways round", ring, Costs{{0, 3}: 5, {3, 0}: 5}, 0, 3, []RouterId{1, 2}, 2},
+		{"diagonal ties", ring, Costs{{0, 3}: 2, {3, 0}: 2}, 0, 3, []RouterId{1, 2, 3}, 2},
+		{"diagonal wins", ring, nil, 0, 3, []RouterId{3}, 1},
+		{"costs break the tie", ring, Costs{{0, 3}: 5, {2, 3}: 3}, 0, 3, []RouterId{1}, 2},
+		{"fan out then in", Template{{1, 2}, {0, 3}, {0, 3}, {1, 2, 4}, {3}}, nil, 0, 4, []RouterId{1, 2}, 3},
+		{"self", ring, nil, 0, 0, nil, 0},
+		{"unreachable", Template{{1}, {0}, {}}, nil, 0, 2, nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hops, cost := EqualCostNextHops(TableFromTemplate(tt.template, tt.costs), tt.start, tt.end)
+			if !reflect.DeepEqual(hops, tt.want) || cost != tt.cost {
+				t.Errorf("EqualCostNextHops(%v, %v) = %v, %v, want %v, %v", tt.start, tt.end, hops, cost, tt.want, tt.cost)
+			}
+		})
+	}
+}
+
+func TestBalancerChoose(t *testing.T) {
+	candidates := []RouterId{1, 2, 3}
+	tests := []struct {
+		name  string
+		mode  string
+		dests []RouterId // Destination of each envelope in turn
+		want  []RouterId
+	}{
+		{"no multipath", "none", []RouterId{5, 5, 6}, []RouterId{1, 1, 1}},
+		{"default", "", []RouterId{5, 6}, []RouterId{1, 1}},
+		{"round robin", "packet", []RouterId{5, 5, 5, 5}, []RouterId{1, 2, 3, 1}},
+		{"round robin per destination", "packet", []RouterId{5, 6, 5, 6, 6}, []RouterId{1, 1, 2, 2, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newBalancer(tt.mode)
+			got := make([]RouterId, len(tt.dests))
+			for i, d := range tt.dests {
+				got[i] = b.choose(0, Envelope{Source: 0, Dest: d}, candidates)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("choose() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBalancerChooseFlow(t *testing.T) {
+	candidates := []RouterId{1, 2, 3}
+	b := newBalancer("flow")
+	used := make(map[RouterId]bool)
+	for label := uint32(0); label < 64; label++ {
+		msg := Envelope{Source: 4, Dest: 5, FlowLabel: label}
+		first := b.choose(0, msg, candidates)
+		if !containsRouter(candidates, first) {
+			t.Fatalf("flow %v sent to %v, not a candidate", label, first)
+		}
+		for i := 0; i < 3; i++ {
+			if again := b.choose(0, msg, candidates); again != first {
+				t.Errorf("flow %v sent to %v then %v", label, first, again)
+			}
+		}
+		used[first] = true
+	}
+	if len(used) != len(candidates) {
+		t.Errorf("64 flows only used next hops %v of %v", used, candidates)
+	}
+}
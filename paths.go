@@ -0,0 +1,307 @@
+package routers
+
+import (
+	"container/heap"
+	"fmt"
+	"sort"
+)
+
+// Route ... A loop free path and its total cost
+type Route struct {
+	Path Path
+	Cost float64
+}
+
+// TableFromTemplate ... Build a table holding every link of the template, so the path computations can be run
+// over a raw topology rather than a router's learned view. Links without an entry in {costs} cost 1
+func TableFromTemplate(t Template, costs Costs) *DVRTable {
+	table := NewDVRTable()
+	for from, neighbours := range t {
+		for _, to := range neighbours {
+			table.Put(RouterId(from), to, Entry{Cost: costs.cost(RouterId(from), to)})
+		}
+	}
+	return table
+}
+
+// KShortestPaths ... Up to {k} least cost loop free paths from {start} to {end} in ascending order of cost,
+// found with Yen's algorithm. Equal cost paths are ordered by their router IDs
+func KShortestPaths(table *DVRTable, start RouterId, end RouterId, k int) []Route {
+	routes := make([]Route, 0, k)
+	if k <= 0 {
+		return routes
+	}
+	first, cost, ok := constrainedShortestPath(table, start, end, nil, nil)
+	if !ok {
+		return routes
+	}
+	routes = append(routes, Route{first, cost})
+	candidates := make([]Route, 0)
+	seen := map[string]bool{pathKey(first): true}
+	for len(routes) < k {
+		previous := routes[len(routes)-1].Path
+		for i := 0; i < len(previous)-1; i++ {
+			spur := previous[i]
+			root := previous[:i+1]
+			// Remove the links used by known paths sharing this root, and the root itself, so the spur deviates
+			removedLinks := make(map[Link]bool)
+			for _, r := range routes {
+				if len(r.Path) > i+1 && samePath(r.Path[:i+1], root) {
+					removedLinks[Link{r.Path[i], r.Path[i+1]}] = true
+				}
+			}
+			removedRouters := make(map[RouterId]bool, i)
+			for _, id := range root[:i] {
+				removedRouters[id] = true
+			}
+			spurPath, spurCost, ok := constrainedShortestPath(table, spur, end, removedRouters, removedLinks)
+			if !ok {
+				continue
+			}
+			path := append(append(make(Path, 0, len(root)+len(spurPath)-1), root[:i]...), spurPath...)
+			if key := pathKey(path); !seen[key] {
+				seen[key] = true
+				candidates = append(candidates, Route{path, pathCost(table, root) + spurCost})
+			}
+		}
+		if len(candidates) == 0 {
+			break
+		}
+		sort.Slice(candidates, func(i, j int) bool { return lessRoute(candidates[i], candidates[j]) })
+		routes = append(routes, candidates[0])
+		candidates = candidates[1:]
+	}
+	return routes
+}
+
+// EdgeDisjointPaths ... A largest set of paths from {start} to {end} sharing no link, found by unit capacity
+// maximum flow. The number of paths is the edge connectivity between the two routers
+func EdgeDisjointPaths(table *DVRTable, start RouterId, end RouterId) []Path {
+	if start == end {
+		return []Path{}
+	}
+	capacity := make(map[RouterId]map[RouterId]int)
+	for _, from := range table.Routers() {
+		for _, to := range table.Neighbours(from) {
+			addCapacity(capacity, from, to)
+		}
+	}
+	flow := maxFlow(capacity, start, end)
+	return decomposeFlow(flow, start, end, func(id RouterId) RouterId { return id })
+}
+
+// NodeDisjointPaths ... A largest set of paths from {start} to {end} sharing no router other than the two ends,
+// found by maximum flow with every other router split into a unit capacity in and out pair
+func NodeDisjointPaths(table *DVRTable, start RouterId, end RouterId) []Path {
+	if start == end {
+		return []Path{}
+	}
+	// Router r becomes in = 2r and out = 2r+1, the ends keep a single vertex
+	in := func(id RouterId) RouterId {
+		if id == start || id == end {
+			return 2*id + 1
+		}
+		return 2 * id
+	}
+	out := func(id RouterId) RouterId { return 2*id + 1 }
+	capacity := make(map[RouterId]map[RouterId]int)
+	for _, from := range table.Routers() {
+		if from != start && from != end {
+			addCapacity(capacity, in(from), out(from))
+		}
+		for _, to := range table.Neighbours(from) {
+			addCapacity(capacity, out(from), in(to))
+		}
+	}
+	flow := maxFlow(capacity, out(start), out(end))
+	paths := decomposeFlow(flow, out(start), out(end), func(id RouterId) RouterId { return id / 2 })
+	for i, p := range paths {
+		// Collapse each split router's in -> out step back into a single visit
+		collapsed := make(Path, 0, len(p))
+		for _, id := range p {
+			if len(collapsed) == 0 || collapsed[len(collapsed)-1] != id {
+				collapsed = append(collapsed, id)
+			}
+		}
+		paths[i] = collapsed
+	}
+	return paths
+}
+
+// constrainedShortestPath ... Dijkstra's algorithm avoiding the given routers and links
+func constrainedShortestPath(table *DVRTable, start RouterId, end RouterId, removedRouters map[RouterId]bool, removedLinks map[Link]bool) (Path, float64, bool) {
+	costs := map[RouterId]float64{start: 0}
+	parents := make(map[RouterId]RouterId)
+	done := make(map[RouterId]bool)
+	queue := &costQueue{{start, 0}}
+	for queue.Len() > 0 {
+		current := heap.Pop(queue).(costItem)
+		if done[current.id] {
+			continue
+		}
+		done[current.id] = true
+		if current.id == end {
+			break
+		}
+		for next, cost := range table.Links(current.id) {
+			if done[next] || removedRouters[next] || removedLinks[Link{current.id, next}] {
+				continue
+			}
+			total := current.cost + cost
+			known, ok := costs[next]
+			if !ok || total < known || (total == known && current.id < parents[next]) {
+				costs[next] = total
+				parents[next] = current.id
+				heap.Push(queue, costItem{next, total})
+			}
+		}
+	}
+	if !done[end] {
+		return nil, 0, false
+	}
+	path := Path{end}
+	for current := end; current != start; {
+		current = parents[current]
+		path = append(path, current)
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path, costs[end], true
+}
+
+// pathCost ... Total cost of the links along {path}
+func pathCost(table *DVRTable, path Path) float64 {
+	total := 0.0
+	for i := 0; i < len(path)-1; i++ {
+		cost, _ := table.Cost(path[i], path[i+1])
+		total += cost
+	}
+	return total
+}
+
+// samePath ... Check two paths visit the same routers in the same order
+func samePath(a Path, b Path) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// pathKey ... Comparable form of a path
+func pathKey(p Path) string {
+	return fmt.Sprint([]RouterId(p))
+}
+
+// lessRoute ... Order routes by cost, then hops, then router IDs
+func lessRoute(a Route, b Route) bool {
+	if a.Cost != b.Cost {
+		return a.Cost < b.Cost
+	}
+	if len(a.Path) != len(b.Path) {
+		return len(a.Path) < len(b.Path)
+	}
+	for i := range a.Path {
+		if a.Path[i] != b.Path[i] {
+			return a.Path[i] < b.Path[i]
+		}
+	}
+	return false
+}
+
+func addCapacity(capacity map[RouterId]map[RouterId]int, from RouterId, to RouterId) {
+	if capacity[from] == nil {
+		capacity[from] = make(map[RouterId]int)
+	}
+	capacity[from][to]++
+	if capacity[to] == nil {
+		capacity[to] = make(map[RouterId]int)
+	}
+}
+
+// maxFlow ... Edmonds-Karp maximum flow, returning the flow carried by each arc. Flow on opposite arcs between
+// the same pair is cancelled so no link is used in both directions
+func maxFlow(capacity map[RouterId]map[RouterId]int, source RouterId, sink RouterId) map[RouterId]map[RouterId]int {
+	// Residual neighbours of each vertex, by an arc or against one, in ascending order
+	adjacent := make(map[RouterId][]RouterId, len(capacity))
+	for u, row := range capacity {
+		for v := range row {
+			adjacent[u] = append(adjacent[u], v)
+			if _, ok := capacity[v][u]; !ok {
+				adjacent[v] = append(adjacent[v], u)
+			}
+		}
+	}
+	for _, ids := range adjacent {
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	}
+	flow := make(map[RouterId]map[RouterId]int, len(capacity))
+	for id := range capacity {
+		flow[id] = make(map[RouterId]int)
+	}
+	for {
+		// Breadth first search for the shortest augmenting path
+		parents := map[RouterId]RouterId{source: source}
+		queue := []RouterId{source}
+		for len(queue) > 0 {
+			u := queue[0]
+			queue = queue[1:]
+			for _, v := range adjacent[u] {
+				if _, seen := parents[v]; !seen && capacity[u][v]-flow[u][v]+flow[v][u] > 0 {
+					parents[v] = u
+					queue = append(queue, v)
+				}
+			}
+		}
+		if _, ok := parents[sink]; !ok {
+			return flow
+		}
+		for v := sink; v != source; v = parents[v] {
+			u := parents[v]
+			if flow[v][u] > 0 {
+				flow[v][u]--
+			} else {
+				flow[u][v]++
+			}
+		}
+	}
+}
+
+// decomposeFlow ... Split a unit flow into loop free paths from {source} to {sink}, mapping each vertex with {router}
+func decomposeFlow(flow map[RouterId]map[RouterId]int, source RouterId, sink RouterId, router func(RouterId) RouterId) []Path {
+	paths := make([]Path, 0)
+	for {
+		vertices := []RouterId{source}
+		for u := source; u != sink; {
+			next, found := RouterId(0), false
+			for v, f := range flow[u] {
+				if f > 0 && (!found || v < next) {
+					next, found = v, true
+				}
+			}
+			if !found {
+				return paths
+			}
+			flow[u][next]--
+			// Drop any cycle the flow took on the way
+			for i, v := range vertices {
+				if v == next {
+					vertices = vertices[:i]
+					break
+				}
+			}
+			vertices = append(vertices, next)
+			u = next
+		}
+		path := make(Path, len(vertices))
+		for i, v := range vertices {
+			path[i] = router(v)
+		}
+		paths = append(paths, path)
+	}
+}
@@ -0,0 +1,120 @@
+package routers
+
+import (
+	"reflect"
+	"testing"
+)
+
+// square ... Four routers in a ring 0-1-3-2-0 with a costly diagonal between 0 and 3
+var square = WeightedTemplate{
+	Template: Template{{1, 2, 3}, {0, 3}, {0, 3}, {1, 2, 0}},
+	Costs:    Costs{{0, 3}: 5, {3, 0}: 5},
+}
+
+func TestKShortestPaths(t *testing.T) {
+	tests := []struct {
+		name     string
+		weighted WeightedTemplate
+		start    RouterId
+		end      RouterId
+		k        int
+		want     []Route
+	}{
+		{"none asked", square, 0, 3, 0, []Route{}},
+		{"shortest", square, 0, 3, 1, []Route{{Path{0, 1, 3}, 2}}},
+		{"equal cost ordered by router", square, 0, 3, 2, []Route{{Path{0, 1, 3}, 2}, {Path{0, 2, 3}, 2}}},
+		{
+			"every path",
+			square, 0, 3, 5,
+			[]Route{{Path{0, 1, 3}, 2}, {Path{0, 2, 3}, 2}, {Path{0, 3}, 5}},
+		},
+		{
+			"costs skew the order",
+			WeightedTemplate{Template{{1, 2}, {0, 2}, {0, 1}}, Costs{{0, 2}: 3}},
+			0, 2, 2,
+			[]Route{{Path{0, 1, 2}, 2}, {Path{0, 2}, 3}},
+		},
+		{"unreachable", WeightedTemplate{Template: Template{{1}, {0}, {}}}, 0, 2, 3, []Route{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			table := TableFromTemplate(tt.weighted.Template, tt.weighted.Costs)
+			if got := KShortestPaths(table, tt.start, tt.end, tt.k); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("KShortestPaths(%v, %v, %v) = %v, want %v", tt.start, tt.end, tt.k, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDisjointPaths(t *testing.T) {
+	// Two triangles sharing router 2: two links leave 0 and reach 4 but every path crosses 2
+	bowtie := Template{{1, 2}, {0, 2}, {0, 1, 3, 4}, {2, 4}, {2, 3}}
+	tests := []struct {
+		name     string
+		template Template
+		start    RouterId
+		end      RouterId
+		edge     int // Expected number of link disjoint paths
+		node     int // Expected number of router disjoint paths
+	}{
+		{"same router", square.Template, 1, 1, 0, 0},
+		{"square", square.Template, 0, 3, 3, 3},
+		{"square neighbours", square.Template, 1, 2, 2, 2},
+		{"bowtie", bowtie, 0, 4, 2, 1},
+		{"line", Template{{1}, {0, 2}, {1}}, 0, 2, 1, 1},
+		{"disconnected", Template{{1}, {0}, {3}, {2}}, 0, 3, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			table := TableFromTemplate(tt.template, nil)
+			edge := EdgeDisjointPaths(table, tt.start, tt.end)
+			if len(edge) != tt.edge {
+				t.Errorf("EdgeDisjointPaths(%v, %v) = %v, want %v paths", tt.start, tt.end, edge, tt.edge)
+			}
+			checkPaths(t, tt.template, tt.start, tt.end, edge)
+			links := make(map[Link]bool)
+			for _, p := range edge {
+				for i := 0; i < len(p)-1; i++ {
+					l := Link{From: p[i], To: p[i+1]}
+					if l.From > l.To {
+						l.From, l.To = l.To, l.From
+					}
+					if links[l] {
+						t.Errorf("EdgeDisjointPaths(%v, %v) = %v shares link %v", tt.start, tt.end, edge, l)
+					}
+					links[l] = true
+				}
+			}
+			node := NodeDisjointPaths(table, tt.start, tt.end)
+			if len(node) != tt.node {
+				t.Errorf("NodeDisjointPaths(%v, %v) = %v, want %v paths", tt.start, tt.end, node, tt.node)
+			}
+			checkPaths(t, tt.template, tt.start, tt.end, node)
+			visited := make(map[RouterId]bool)
+			for _, p := range node {
+				for _, id := range p[1 : len(p)-1] {
+					if visited[id] {
+						t.Errorf("NodeDisjointPaths(%v, %v) = %v shares router %v", tt.start, tt.end, node, id)
+					}
+					visited[id] = true
+				}
+			}
+		})
+	}
+}
+
+// checkPaths ... Fail unless every path runs from {start} to {end} over links of the template
+func checkPaths(t *testing.T, template Template, start RouterId, end RouterId, paths []Path) {
+	t.Helper()
+	for _, p := range paths {
+		if len(p) < 2 || p[0] != start || p[len(p)-1] != end {
+			t.Errorf("path %v doesn't run from %v to %v", p, start, end)
+			continue
+		}
+		for i := 0; i < len(p)-1; i++ {
+			if template.neighbourIndex(p[i], p[i+1]) < 0 {
+				t.Errorf("path %v steps over missing link %v -> %v", p, p[i], p[i+1])
+			}
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package routers
+
+import (
+	"log"
+	"math/bits"
+)
+
+// prefixTrie ... Path compressed binary (Patricia) trie of IPv4 prefixes, each held by the routers advertising it
+type prefixTrie struct {
+	root *trieNode
+}
+
+// trieNode ... A prefix of {length} bits, with the bits beyond it zeroed. Nodes without owners only branch
+type trieNode struct {
+	key      uint32
+	length   uint
+	owners   []RouterId
+	children [2]*trieNode
+}
+
+// bitAt ... The {i}th most significant bit of {key}
+func bitAt(key uint32, i uint) int {
+	return int(key>>(31-i)) & 1
+}
+
+// maskTo ... {key} with all but its first {length} bits zeroed
+func maskTo(key uint32, length uint) uint32 {
+	if length == 0 {
+		return 0
+	}
+	return key & (^uint32(0) << (32 - length))
+}
+
+// commonLength ... Number of leading bits two prefixes share
+func commonLength(a uint32, aLength uint, b uint32, bLength uint) uint {
+	common := uint(bits.LeadingZeros32(a ^ b))
+	if aLength < common {
+		common = aLength
+	}
+	if bLength < common {
+		common = bLength
+	}
+	return common
+}
+
+// insert ... Add {owner} to the routers holding the prefix
+func (t *prefixTrie) insert(key uint32, length uint, owner RouterId) {
+	t.root = insertPrefix(t.root, maskTo(key, length), length, owner)
+}
+
+func insertPrefix(n *trieNode, key uint32, length uint, owner RouterId) *trieNode {
+	if n == nil {
+		return &trieNode{key: key, length: length, owners: []RouterId{owner}}
+	}
+	common := commonLength(n.key, n.length, key, length)
+	switch {
+	case common == n.length && common == length:
+		if !containsRouter(n.owners, owner) {
+			n.owners = append(n.owners, owner)
+		}
+		return n
+	case common == n.length:
+		// Longer than this node, so it belongs below
+		b := bitAt(key, n.length)
+		n.children[b] = insertPrefix(n.children[b], key, length, owner)
+		return n
+	case common == length:
+		// Shorter than this node, so it goes above
+		parent := &trieNode{key: key, length: length, owners: []RouterId{owner}}
+		parent.children[bitAt(n.key, length)] = n
+		return parent
+	default:
+		branch := &trieNode{key: maskTo(key, common), length: common}
+		branch.children[bitAt(key, common)] = &trieNode{key: key, length: length, owners: []RouterId{owner}}
+		branch.children[bitAt(n.key, common)] = n
+		return branch
+	}
+}
+
+// remove ... Take {owner} off the routers holding the prefix, collapsing nodes that no longer branch
+func (t *prefixTrie) remove(key uint32, length uint, owner RouterId) {
+	t.root = removePrefix(t.root, maskTo(key, length), length, owner)
+}
+
+func removePrefix(n *trieNode, key uint32, length uint, owner RouterId) *trieNode {
+	if n == nil || commonLength(n.key, n.length, key, length) < n.length {
+		return n
+	}
+	if n.length < length {
+		b := bitAt(key, n.length)
+		n.children[b] = removePrefix(n.children[b], key, length, owner)
+	} else {
+		kept := n.owners[:0]
+		for _, id := range n.owners {
+			if id != owner {
+				kept = append(kept, id)
+			}
+		}
+		n.owners = kept
+	}
+	if len(n.owners) > 0 {
+		return n
+	}
+	switch {
+	case n.children[0] == nil:
+		return n.children[1]
+	case n.children[1] == nil:
+		return n.children[0]
+	default:
+		return n
+	}
+}
+
+// lookup ... The longest prefix holding {addr} and the routers holding it
+func (t *prefixTrie) lookup(addr uint32) (uint32, uint, []RouterId, bool) {
+	var best *trieNode
+	for n := t.root; n != nil; {
+		if commonLength(n.key, n.length, addr, 32) < n.length {
+			break
+		}
+		if len(n.owners) > 0 {
+			best = n
+		}
+		if n.length == 32 {
+			break
+		}
+		n = n.children[bitAt(addr, n.length)]
+	}
+	if best == nil {
+		return 0, 0, nil, false
+	}
+	return best.key, best.length, append([]RouterId(nil), best.owners...), true
+}
+
+// resolveAddress ... Address the envelope to the router advertising the longest prefix holding its destination
+// address, the nearest by least cost should several advertise it. Every router on the way resolves it again, so
+// more specific prefixes learned on the way take over. Without a matching prefix Dest is left as it is
+func resolveAddress(logLevel string, msg *Envelope, self RouterId, networkAddress IPv4, RoutingTable *DVRTable, trees *sourceTrees) {
+	prefix, owners, ok := RoutingTable.LookupPrefix(msg.DestAddress)
+	if !ok {
+		if logLevel != "none" {
+			log.Printf("[%v] No prefix holds %v, routing to [%v]",
+				networkAddress.toString(false),
+				msg.DestAddress.toString(false),
+				msg.Dest)
+		}
+		return
+	}
+	costs, _ := trees.tree(RoutingTable, self)
+	nearest, found := nearestOf(costs, owners)
+	if !found {
+		if logLevel != "none" {
+			log.Printf("[%v] No reachable router advertises %v, routing to [%v]",
+				networkAddress.toString(false),
+				prefix.toString(true),
+				msg.Dest)
+		}
+		return
+	}
+	if nearest != msg.Dest && logLevel != "none" {
+		log.Printf("[%v] Longest prefix holding %v is %v, advertised by [%v]",
+			networkAddress.toString(false),
+			msg.DestAddress.toString(false),
+			prefix.toString(true),
+			nearest)
+	}
+	msg.Dest = nearest
+}
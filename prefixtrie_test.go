@@ -0,0 +1,173 @@
+package routers
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// trieOp ... Insert, or remove should {remove} be set, {owner}'s claim on {prefix}
+type trieOp struct {
+	prefix IPv4
+	owner  RouterId
+	remove bool
+}
+
+// trieMatch ... Expected result of looking up {addr}: the longest prefix holding it and its owners
+type trieMatch struct {
+	addr   IPv4
+	prefix IPv4
+	owners []RouterId
+	ok     bool
+}
+
+func TestPrefixTrie(t *testing.T) {
+	tests := []struct {
+		name    string
+		ops     []trieOp
+		matches []trieMatch
+	}{
+		{
+			"empty",
+			nil,
+			[]trieMatch{{addr: IPv4{10, 0, 0, 1, 32}}},
+		},
+		{
+			"longest prefix wins",
+			[]trieOp{
+				{prefix: IPv4{10, 0, 0, 0, 8}, owner: 1},
+				{prefix: IPv4{10, 1, 0, 0, 16}, owner: 2},
+				{prefix: IPv4{10, 1, 2, 3, 32}, owner: 3},
+			},
+			[]trieMatch{
+				{IPv4{10, 9, 9, 9, 32}, IPv4{10, 0, 0, 0, 8}, []RouterId{1}, true},
+				{IPv4{10, 1, 9, 9, 32}, IPv4{10, 1, 0, 0, 16}, []RouterId{2}, true},
+				{IPv4{10, 1, 2, 3, 32}, IPv4{10, 1, 2, 3, 32}, []RouterId{3}, true},
+				{addr: IPv4{11, 0, 0, 0, 32}},
+			},
+		},
+		{
+			"shorter prefix inserted above",
+			[]trieOp{
+				{prefix: IPv4{192, 168, 1, 0, 24}, owner: 1},
+				{prefix: IPv4{192, 168, 0, 0, 16}, owner: 2},
+			},
+			[]trieMatch{
+				{IPv4{192, 168, 1, 7, 32}, IPv4{192, 168, 1, 0, 24}, []RouterId{1}, true},
+				{IPv4{192, 168, 2, 7, 32}, IPv4{192, 168, 0, 0, 16}, []RouterId{2}, true},
+			},
+		},
+		{
+			"siblings branch",
+			[]trieOp{
+				{prefix: IPv4{10, 0, 0, 0, 24}, owner: 1},
+				{prefix: IPv4{10, 0, 1, 0, 24}, owner: 2},
+			},
+			[]trieMatch{
+				{IPv4{10, 0, 0, 9, 32}, IPv4{10, 0, 0, 0, 24}, []RouterId{1}, true},
+				{IPv4{10, 0, 1, 9, 32}, IPv4{10, 0, 1, 0, 24}, []RouterId{2}, true},
+				{addr: IPv4{10, 0, 2, 9, 32}},
+			},
+		},
+		{
+			"host bits ignored",
+			[]trieOp{{prefix: IPv4{10, 0, 0, 77, 24}, owner: 1}},
+			[]trieMatch{{IPv4{10, 0, 0, 1, 32}, IPv4{10, 0, 0, 0, 24}, []RouterId{1}, true}},
+		},
+		{
+			"shared prefix",
+			[]trieOp{
+				{prefix: IPv4{10, 0, 0, 0, 24}, owner: 4},
+				{prefix: IPv4{10, 0, 0, 0, 24}, owner: 2},
+				{prefix: IPv4{10, 0, 0, 0, 24}, owner: 4},
+			},
+			[]trieMatch{{IPv4{10, 0, 0, 1, 32}, IPv4{10, 0, 0, 0, 24}, []RouterId{2, 4}, true}},
+		},
+		{
+			"default route",
+			[]trieOp{{prefix: IPv4{0, 0, 0, 0, 0}, owner: 9}},
+			[]trieMatch{{IPv4{203, 0, 113, 5, 32}, IPv4{0, 0, 0, 0, 0}, []RouterId{9}, true}},
+		},
+		{
+			"removed owner",
+			[]trieOp{
+				{prefix: IPv4{10, 0, 0, 0, 24}, owner: 1},
+				{prefix: IPv4{10, 0, 0, 0, 24}, owner: 2},
+				{prefix: IPv4{10, 0, 0, 0, 24}, owner: 1, remove: true},
+			},
+			[]trieMatch{{IPv4{10, 0, 0, 1, 32}, IPv4{10, 0, 0, 0, 24}, []RouterId{2}, true}},
+		},
+		{
+			"removed prefix falls back",
+			[]trieOp{
+				{prefix: IPv4{10, 0, 0, 0, 8}, owner: 1},
+				{prefix: IPv4{10, 1, 0, 0, 16}, owner: 2},
+				{prefix: IPv4{10, 1, 1, 0, 24}, owner: 3},
+				{prefix: IPv4{10, 1, 0, 0, 16}, owner: 2, remove: true},
+			},
+			[]trieMatch{
+				{IPv4{10, 1, 9, 9, 32}, IPv4{10, 0, 0, 0, 8}, []RouterId{1}, true},
+				{IPv4{10, 1, 1, 9, 32}, IPv4{10, 1, 1, 0, 24}, []RouterId{3}, true},
+			},
+		},
+		{
+			"removing a branch keeps its children",
+			[]trieOp{
+				{prefix: IPv4{10, 0, 0, 0, 24}, owner: 1},
+				{prefix: IPv4{10, 0, 1, 0, 24}, owner: 2},
+				{prefix: IPv4{10, 0, 0, 0, 24}, owner: 1, remove: true},
+			},
+			[]trieMatch{
+				{addr: IPv4{10, 0, 0, 9, 32}},
+				{IPv4{10, 0, 1, 9, 32}, IPv4{10, 0, 1, 0, 24}, []RouterId{2}, true},
+			},
+		},
+		{
+			"removing an unknown prefix",
+			[]trieOp{
+				{prefix: IPv4{10, 0, 0, 0, 24}, owner: 1},
+				{prefix: IPv4{10, 0, 0, 0, 25}, owner: 1, remove: true},
+				{prefix: IPv4{10, 0, 0, 0, 24}, owner: 7, remove: true},
+			},
+			[]trieMatch{{IPv4{10, 0, 0, 1, 32}, IPv4{10, 0, 0, 0, 24}, []RouterId{1}, true}},
+		},
+		{
+			"everything removed",
+			[]trieOp{
+				{prefix: IPv4{10, 0, 0, 0, 8}, owner: 1},
+				{prefix: IPv4{10, 1, 0, 0, 16}, owner: 2},
+				{prefix: IPv4{10, 0, 0, 0, 8}, owner: 1, remove: true},
+				{prefix: IPv4{10, 1, 0, 0, 16}, owner: 2, remove: true},
+			},
+			[]trieMatch{{addr: IPv4{10, 1, 0, 1, 32}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var trie prefixTrie
+			for _, op := range tt.ops {
+				if op.remove {
+					trie.remove(op.prefix.toUint32(), op.prefix.Prefix, op.owner)
+				} else {
+					trie.insert(op.prefix.toUint32(), op.prefix.Prefix, op.owner)
+				}
+			}
+			for _, m := range tt.matches {
+				key, length, owners, ok := trie.lookup(m.addr.toUint32())
+				sort.Slice(owners, func(i, j int) bool { return owners[i] < owners[j] })
+				if ok != m.ok {
+					t.Errorf("lookup(%v) found %v, want %v", m.addr.toString(false), ok, m.ok)
+					continue
+				}
+				if !ok {
+					continue
+				}
+				got := ipv4FromUint32(key, length)
+				if got != m.prefix || !reflect.DeepEqual(owners, m.owners) {
+					t.Errorf("lookup(%v) = %v held by %v, want %v held by %v",
+						m.addr.toString(false), got.toString(true), owners, m.prefix.toString(true), m.owners)
+				}
+			}
+		})
+	}
+}
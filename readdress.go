@@ -0,0 +1,155 @@
+package routers
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"time"
+)
+
+// readdressModes ... Supported values of Config.Readdressing
+var readdressModes = []string{"", "none", "static", "dynamic", "dns"}
+
+// defaultAliasHold ... Time a router renumbered under the dns mode keeps answering to its old address when
+// Config.AliasHold is unset
+const defaultAliasHold = time.Second
+
+// aliasHoldOf ... The configured alias hold, or the default
+func aliasHoldOf(cfg Config) time.Duration {
+	if cfg.AliasHold == 0 {
+		return defaultAliasHold
+	}
+	return cfg.AliasHold
+}
+
+// validReaddressing ... Check the re-addressing mode is supported and its alias hold isn't negative
+func validReaddressing(cfg Config) error {
+	if cfg.AliasHold < 0 {
+		return fmt.Errorf("alias hold %v is negative", cfg.AliasHold)
+	}
+	for _, m := range readdressModes {
+		if m == cfg.Readdressing {
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported re-addressing mode %q (expected none, static, dynamic or dns)", cfg.Readdressing)
+}
+
+// LinkUp ... A new link to the neighbour {ID} over its input {Channel}, as detected by the physical layer
+type LinkUp struct {
+	ID      RouterId
+	Channel chan<- interface{}
+}
+
+// Connect ... Bring up a link between routers {a} and {b}, giving each the other's input channel
+func Connect(in []chan<- interface{}, a RouterId, b RouterId) {
+	in[a] <- LinkUp{ID: b, Channel: in[b]}
+	in[b] <- LinkUp{ID: a, Channel: in[a]}
+}
+
+// Disconnect ... Take down the link between routers {a} and {b}
+func Disconnect(in []chan<- interface{}, a RouterId, b RouterId) {
+	in[a] <- LinkDown{ID: b}
+	in[b] <- LinkDown{ID: a}
+}
+
+// aliasSet ... Old host addresses a router still answers to after renumbering, and when each expires
+type aliasSet map[IPv4]time.Time
+
+// prefixes ... What the router advertises, its host address, its block and its aliases
+func (a aliasSet) prefixes(address IPv4) []IPv4 {
+	host := address
+	host.Prefix = 32
+	prefixes := []IPv4{host, address}
+	for alias := range a {
+		prefixes = append(prefixes, alias)
+	}
+	sort.Slice(prefixes[2:], func(i, j int) bool {
+		return prefixes[2+i].toUint32() < prefixes[2+j].toUint32()
+	})
+	return prefixes
+}
+
+// expire ... Drop the aliases that have expired, reporting whether there were any
+func (a aliasSet) expire(now time.Time) bool {
+	expired := false
+	for alias, until := range a {
+		if now.After(until) {
+			delete(a, alias)
+			expired = true
+		}
+	}
+	return expired
+}
+
+// processLinkUp ... Start forwarding to the new neighbour and advertise the link. The neighbour's channel is added
+// should it be new, returning the router's neighbour channels
+func processLinkUp(logLevel string, msg LinkUp, self RouterId, networkAddress IPv4, RouterIPAddress IPv4, RoutingTable *DVRTable, neighbours []chan<- interface{}, NMap NeighbourMap, st *routerState) []chan<- interface{} {
+	if index, ok := NMap[msg.ID]; ok {
+		if !st.fib.down[msg.ID] {
+			return neighbours
+		}
+		st.fib.restore(msg.ID)
+		neighbours[index] = msg.Channel
+	} else {
+		neighbours = append(neighbours, msg.Channel)
+		NMap[msg.ID] = len(neighbours) - 1
+	}
+	if logLevel != "none" {
+		log.Printf("[%v] Link to neighbour [%v] is up", networkAddress.toString(false), msg.ID)
+	}
+	now := time.Now()
+	RoutingTable.Put(self, msg.ID, Entry{st.costs.cost(self, msg.ID), self, now, st.sequences[self]})
+	RoutingTable.Put(msg.ID, self, Entry{st.costs.cost(msg.ID, self), self, now, st.sequences[self]})
+	advertiseLinks(logLevel, self, networkAddress, RouterIPAddress, RoutingTable, neighbours, st)
+	return neighbours
+}
+
+// readdress ... Renumber the router once its live neighbours need a block of another size. Static keeps the host
+// address, recomputing the block's prefix around it. Dynamic moves to a block of the new size, from the supernet
+// unless allocating at random. DNS moves as dynamic, still answering to the old address until its alias expires so
+// that envelopes already addressed to it aren't lost. The new prefixes are advertised at once. Returns the router's
+// address and network address, changed or not
+func readdress(logLevel string, self RouterId, networkAddress IPv4, RouterIPAddress IPv4, RoutingTable *DVRTable, neighbours []chan<- interface{}, NMap NeighbourMap, st *routerState) (IPv4, IPv4) {
+	if st.cfg.Readdressing == "" || st.cfg.Readdressing == "none" {
+		return RouterIPAddress, networkAddress
+	}
+	prefix := blockPrefix(len(st.fib.live(neighbours, NMap)))
+	if prefix == RouterIPAddress.Prefix {
+		return RouterIPAddress, networkAddress
+	}
+	block := RouterIPAddress
+	block.Prefix = prefix
+	if st.cfg.Readdressing != "static" {
+		moved, ok := randomIPv4WithPrefix(st.rng, prefix), true
+		if st.cfg.Allocation == "sequential" || st.cfg.Allocation == "negotiated" {
+			moved, ok = freeBlock(st.rng, supernetOf(st.cfg), prefix, takenBlocks(RoutingTable, self))
+		}
+		if !ok {
+			if logLevel != "none" {
+				log.Printf("[%v] No block for %v neighbours is free in %v, keeping %v",
+					networkAddress.toString(false),
+					len(st.fib.live(neighbours, NMap)),
+					supernetOf(st.cfg).toString(true),
+					RouterIPAddress.toString(true))
+			}
+			return RouterIPAddress, networkAddress
+		}
+		block = moved
+		if st.cfg.Readdressing == "dns" {
+			old := RouterIPAddress
+			old.Prefix = 32
+			st.aliases[old] = time.Now().Add(aliasHoldOf(st.cfg))
+		}
+	}
+	if logLevel != "none" {
+		log.Printf("[%v] Renumbering %v to %v",
+			networkAddress.toString(false),
+			RouterIPAddress.toString(true),
+			block.toString(true))
+	}
+	_, network := block.networkID()
+	RoutingTable.SetPrefixes(self, st.aliases.prefixes(block))
+	advertiseLinks(logLevel, self, network, block, RoutingTable, neighbours, st)
+	return block, network
+}
@@ -0,0 +1,137 @@
+package routers
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestReaddress(t *testing.T) {
+	address := IPv4{10, 0, 0, 1, 30}
+	supernet := IPv4{10, 0, 0, 0, 29}
+	tests := []struct {
+		name       string
+		cfg        Config
+		neighbours int
+		down       []RouterId // Neighbours whose links have gone down
+		taken      []IPv4     // Blocks advertised by router 9
+		want       IPv4
+		advertised bool
+		alias      bool
+	}{
+		{"unset", Config{}, 3, []RouterId{2, 3}, nil, address, false, false},
+		{"none", Config{Readdressing: "none"}, 3, []RouterId{2, 3}, nil, address, false, false},
+		{"same size", Config{Readdressing: "dynamic"}, 3, nil, nil, address, false, false},
+		{"static", Config{Readdressing: "static"}, 3, []RouterId{2, 3}, nil, IPv4{10, 0, 0, 1, 31}, true, false},
+		{
+			"dynamic",
+			Config{Readdressing: "dynamic", Allocation: "sequential", Supernet: supernet},
+			3,
+			[]RouterId{2, 3},
+			[]IPv4{{10, 0, 0, 0, 30}, {10, 0, 0, 6, 31}},
+			IPv4{10, 0, 0, 4, 31},
+			true,
+			false,
+		},
+		{
+			"dynamic with the supernet full",
+			Config{Readdressing: "dynamic", Allocation: "negotiated", Supernet: supernet},
+			3,
+			[]RouterId{2, 3},
+			[]IPv4{{10, 0, 0, 0, 30}, {10, 0, 0, 4, 30}},
+			address,
+			false,
+			false,
+		},
+		{
+			"dns",
+			Config{Readdressing: "dns", Allocation: "sequential", Supernet: supernet},
+			3,
+			[]RouterId{2, 3},
+			[]IPv4{{10, 0, 0, 0, 30}, {10, 0, 0, 6, 31}},
+			IPv4{10, 0, 0, 4, 31},
+			true,
+			true,
+		},
+		{
+			"fourth neighbour up",
+			Config{Readdressing: "dynamic", Allocation: "sequential", Supernet: IPv4{10, 0, 0, 0, 28}},
+			4,
+			nil,
+			[]IPv4{{10, 0, 0, 0, 30}},
+			IPv4{10, 0, 0, 9, 29},
+			true,
+			false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			neighbours := make([]chan<- interface{}, 0)
+			NMap := make(NeighbourMap)
+			for i := 0; i < tt.neighbours; i++ {
+				neighbours = append(neighbours, make(chan interface{}, 1))
+				NMap[RouterId(i+1)] = i
+			}
+			st := newRouterState(tt.cfg, nil)
+			for _, id := range tt.down {
+				st.fib.fail(id)
+			}
+			table := NewDVRTable()
+			table.SetPrefixes(0, st.aliases.prefixes(address))
+			table.SetPrefixes(9, tt.taken)
+			got, _ := readdress("none", 0, IPv4{}, address, table, neighbours, NMap, st)
+			if got != tt.want {
+				t.Errorf("readdressed %v to %v, want %v", address.toString(true), got.toString(true), tt.want.toString(true))
+			}
+			if advertised := st.sequences[0] > 0; advertised != tt.advertised {
+				t.Errorf("advertised %v, want %v", advertised, tt.advertised)
+			}
+			old := address
+			old.Prefix = 32
+			if _, alias := st.aliases[old]; alias != tt.alias {
+				t.Errorf("alias for %v held %v, want %v", old.toString(true), alias, tt.alias)
+			}
+			host := got
+			host.Prefix = 32
+			want := []IPv4{host, got}
+			if tt.alias {
+				want = append(want, old)
+			}
+			if tt.advertised {
+				if prefixes := table.Prefixes(0); !reflect.DeepEqual(prefixes, want) {
+					t.Errorf("prefixes %v, want %v", prefixes, want)
+				}
+			}
+		})
+	}
+}
+
+func TestAliasExpiry(t *testing.T) {
+	now := time.Now()
+	aliases := aliasSet{
+		{10, 0, 0, 1, 32}: now.Add(-time.Second),
+		{10, 0, 0, 9, 32}: now.Add(time.Second),
+	}
+	address := IPv4{10, 0, 0, 5, 31}
+	tests := []struct {
+		name    string
+		at      time.Time
+		expired bool
+		want    []IPv4
+	}{
+		{"before either expires", now.Add(-2 * time.Second), false, []IPv4{{10, 0, 0, 5, 32}, address, {10, 0, 0, 1, 32}, {10, 0, 0, 9, 32}}},
+		{"one expired", now, true, []IPv4{{10, 0, 0, 5, 32}, address, {10, 0, 0, 9, 32}}},
+		{"nothing left to expire", now, false, []IPv4{{10, 0, 0, 5, 32}, address, {10, 0, 0, 9, 32}}},
+		{"both expired", now.Add(2 * time.Second), true, []IPv4{{10, 0, 0, 5, 32}, address}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if expired := aliases.expire(tt.at); expired != tt.expired {
+				t.Errorf("expire() = %v, want %v", expired, tt.expired)
+			}
+			if got := aliases.prefixes(address); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("prefixes() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"log"
 	"math/rand"
+	"sync/atomic"
+	"time"
 )
 
 // #### CONSTANTS ####
@@ -28,11 +30,19 @@ func (r Routers) contains(id RouterId) bool {
 
 // TopologyUpdate ... In order record of routers visited when initialising
 type TopologyUpdate struct {
-	ID   UUID
-	IP   IPv4
-	Path Routers
+	ID       UUID
+	IP       IPv4
+	Path     Routers
+	Costs    [][2]float64         // Cost of each link along Path, forwards then backwards
+	Sequence uint64               // Origin's (Path[0]) sequence number, each is forwarded once by every router
+	Links    map[RouterId]float64 // Origin's direct links and their costs, nil when sent for neighbour discovery
+	Groups   []GroupId            // Multicast groups the origin has joined, sent alongside Links
+	Prefixes []IPv4               // IPv4 prefixes the origin advertises, sent alongside Links
 }
 
+// originSequences ... Latest sequence number seen from each origin, the entry for self is the last one sent
+type originSequences map[RouterId]uint64
+
 // NeighbourUpdate ... Pass the ID of the self to neighbours
 type NeighbourUpdate struct {
 	ID     RouterId
@@ -42,10 +52,28 @@ type NeighbourUpdate struct {
 // NeighbourMap ... Mapping of RouterId to local channel index
 type NeighbourMap map[RouterId]int
 
-func (n NeighbourMap) getAllNotIn(values Routers) []int {
-	returnValue := make([]int, 0)
+// idOf ... Find the RouterId mapped to a local channel index
+func (n NeighbourMap) idOf(index int) (RouterId, bool) {
+	for r, i := range n {
+		if i == index {
+			return r, true
+		}
+	}
+	return 0, false
+}
+
+// getAllNotIn ... Indexes of the {count} neighbour channels, excluding those mapped to one of {values}.
+// Channels not yet mapped are included
+func (n NeighbourMap) getAllNotIn(values Routers, count int) []int {
+	excluded := make(map[int]bool)
 	for r, i := range n {
-		if !values.contains(r) {
+		if values.contains(r) {
+			excluded[i] = true
+		}
+	}
+	returnValue := make([]int, 0, count)
+	for i := 0; i < count; i++ {
+		if !excluded[i] {
 			returnValue = append(returnValue, i)
 		}
 	}
@@ -73,30 +101,99 @@ func processNeighbourUpdate(logLevel string, msg NeighbourUpdate, neighbours []c
 // ---- Envelope ----
 
 // forwardEnvelope ... Calculate the shortest path to the destination and forward the message to the next router in the path
-func forwardEnvelope(logLevel string, msg Envelope, RoutingTable DVRTable, self RouterId, networkAddress IPv4, neighbours []chan<- interface{}, NMap NeighbourMap, raw interface{}, RouterIPAddress IPv4) {
+func forwardEnvelope(logLevel string, msg Envelope, RoutingTable *DVRTable, self RouterId, networkAddress IPv4, neighbours []chan<- interface{}, NMap NeighbourMap, raw interface{}, RouterIPAddress IPv4, st *routerState) {
 	msg.Hops++
-	newPath := make(Path, 0)
-	// Calculate the shortest path from the current node to the destination
-	next := AsyncShortestPath(RoutingTable, self, msg.Dest, newPath)
-	if len(next) > 0 {
+	if st.labels.enabled && msg.Hops == 1 {
+		// Injected here, so this router is the ingress of the envelope's label switched path
+		if pushLabels(logLevel, &msg, RoutingTable, self, networkAddress, neighbours, NMap, raw, st) {
+			return
+		}
+	}
+	ctx := hopContext{self, NMap, st.balance, st.fib}
+	// Head for the next segment of the source route, if any, before the destination
+	target := msg.Dest
+	if len(msg.Segments) > 0 {
+		target = msg.Segments[0]
+	}
+	if msg.Strict {
+		if ctx.usable(target) {
+			if logLevel != "none" {
+				log.Printf("[%v] Following strict source route to [%v]",
+					networkAddress.toString(false),
+					target)
+			}
+			sendEnvelope(logLevel, msg, target, self, networkAddress, neighbours, NMap, raw, st)
+			return
+		}
 		if logLevel != "none" {
-			log.Printf("[%v] Found shortest path %v",
+			log.Printf("[%v] Strict source route broken, [%v] is not a live neighbour, routing to [%v] instead",
 				networkAddress.toString(false),
+				target,
+				msg.Dest)
+		}
+		msg.Segments, msg.Strict = nil, false
+		target = msg.Dest
+	}
+	if st.algorithm != nil && !msg.tableRouted && target == msg.Dest {
+		// Routed without the table where possible, falling back to it should the hop be unusable
+		if next, ok := st.algorithm.nextHop(ctx, &msg); ok {
+			if logLevel != "none" {
+				log.Printf("[%v] Routing by %v to [%v]",
+					networkAddress.toString(false),
+					st.algorithm.name(),
+					next)
+			}
+			sendEnvelope(logLevel, msg, next, self, networkAddress, neighbours, NMap, raw, st)
+			return
+		}
+		msg.tableRouted = true
+	}
+	// Look up the next hops on the least cost paths from the current node to the destination
+	lookup := time.Now()
+	candidates, pathCost, rerouted := st.fib.nextHops(RoutingTable, self, target, NMap)
+	st.labels.counters.Routed++
+	st.labels.counters.RouteTime += time.Since(lookup)
+	if len(candidates) > 0 {
+		next := st.balance.choose(self, msg, candidates)
+		if rerouted {
+			if !msg.Rerouted {
+				msg.Rerouted = true
+				st.fib.saved++
+			}
+			if logLevel != "none" {
+				log.Printf("[%v] Next hops to [%v] are down, fast rerouting to loop free alternate [%v]",
+					networkAddress.toString(false),
+					target,
+					next)
+			}
+		} else if logLevel != "none" {
+			log.Printf("[%v] Found equal cost next hops %v {Cost: %v}, choosing [%v]",
+				networkAddress.toString(false),
+				candidates,
+				pathCost,
 				next)
 		}
+		sendEnvelope(logLevel, msg, next, self, networkAddress, neighbours, NMap, raw, st)
+		return
+	}
+	live := st.fib.live(neighbours, NMap)
+	if len(live) == 0 {
 		if logLevel != "none" {
-			log.Printf("| >> [%s] ~ [%v] {Envelope: %v} Forwarding to neighbours..",
+			log.Printf("[%v] No neighbours to forward envelope for [%v] to, dropping",
 				networkAddress.toString(false),
-				neighbours[NMap[next[1]]],
-				&raw)
+				target)
 		}
-		// Send that to the next router in the path (index 0 is self)
-		neighbours[NMap[next[1]]] <- msg
 		return
 	}
 	// If there was no path (network not mapped deep enough)
 	// then send to a random neighbour and send a new network mapping message
-	nextHop := rand.Intn(len(neighbours))
+	nextHop := live[st.rng.Intn(len(live))]
+	if id, ok := NMap.idOf(nextHop); ok {
+		msg.Cost += st.costs.cost(self, id)
+		st.balance.sent(id)
+	} else {
+		msg.Cost++
+	}
 	if logLevel != "none" {
 		log.Printf("[%v] Shortest path not found, routing to random neighbour: %v",
 			networkAddress.toString(false),
@@ -107,20 +204,55 @@ func forwardEnvelope(logLevel string, msg Envelope, RoutingTable DVRTable, self
 			&raw)
 	}
 	// Send that to the next router in the path (index 0 is self)
-	go func() {
-		neighbours[nextHop] <- msg
-	}()
+	go sendOrStop(neighbours[nextHop], msg, st.cfg.Done)
 
 	// Send new network mapping message
 	_, nextHost := RouterIPAddress.firstHostID()
 	CurrPath := make(Routers, 0)
 	CurrPath = append(CurrPath, self)
 	uuid, _ := uuid4()
-	sendTopologyUpdate(logLevel, networkAddress, uuid, nextHost, CurrPath, neighbours[rand.Intn(len(neighbours))])
+	st.sequences[self]++
+	sendTopologyUpdate(logLevel, networkAddress, uuid, nextHost, CurrPath, st.sequences[self], RoutingTable.Links(self), RoutingTable.Groups(self), RoutingTable.Prefixes(self), neighbours[live[st.rng.Intn(len(live))]], st.cfg.Done)
+}
+
+// sendEnvelope ... Forward the envelope to the neighbour {next}, whose channel must be mapped
+func sendEnvelope(logLevel string, msg Envelope, next RouterId, self RouterId, networkAddress IPv4, neighbours []chan<- interface{}, NMap NeighbourMap, raw interface{}, st *routerState) {
+	msg.Cost += st.costs.cost(self, next)
+	st.balance.sent(next)
+	if logLevel != "none" {
+		log.Printf("| >> [%s] ~ [%v] {Envelope: %v} Forwarding to neighbours..",
+			networkAddress.toString(false),
+			neighbours[NMap[next]],
+			&raw)
+	}
+	// Send that to the next router in the path, without blocking
+	// this router should the neighbour be busy forwarding towards us
+	msg.previous = self
+	queued := st.balance.queue(next)
+	go func(ns chan<- interface{}) {
+		sendOrStop(ns, msg, st.cfg.Done)
+		atomic.AddInt64(queued, -1)
+	}(neighbours[NMap[next]])
 }
 
-func processEnvelope(logLevel string, msg Envelope, self RouterId, framework chan<- Envelope, networkAddress IPv4, incoming <-chan interface{}, raw interface{}, RoutingTable DVRTable, neighbours []chan<- interface{}, NMap NeighbourMap, RouterIPAddress IPv4) {
-	if msg.Dest == self {
+func processEnvelope(logLevel string, msg Envelope, self RouterId, framework chan<- Envelope, networkAddress IPv4, incoming <-chan interface{}, raw interface{}, RoutingTable *DVRTable, neighbours []chan<- interface{}, NMap NeighbourMap, RouterIPAddress IPv4, st *routerState) {
+	if msg.Broadcast || msg.Group != 0 {
+		processGroupEnvelope(logLevel, msg, self, framework, networkAddress, RouterIPAddress, raw, RoutingTable, neighbours, NMap, st)
+		return
+	}
+	if msg.Anycast != 0 {
+		resolveAnycast(logLevel, &msg, self, networkAddress, RoutingTable, st.trees)
+	}
+	if msg.DestAddress != (IPv4{}) {
+		resolveAddress(logLevel, &msg, self, networkAddress, RoutingTable, st.trees)
+	}
+	if switchLabels(logLevel, &msg, self, networkAddress, neighbours, NMap, raw, st) {
+		return
+	}
+	for len(msg.Segments) > 0 && msg.Segments[0] == self {
+		msg.Segments = msg.Segments[1:]
+	}
+	if msg.Dest == self && len(msg.Segments) == 0 {
 		if logLevel != "none" {
 			log.Printf("| << [%v] ~ [%v] {Envelope: %v} --TERMINATED-- HOPS: %v",
 				networkAddress.toString(false),
@@ -128,33 +260,85 @@ func processEnvelope(logLevel string, msg Envelope, self RouterId, framework cha
 				&raw,
 				msg.Hops)
 		}
-		framework <- msg
+		msg.Delivered = time.Now()
+		select {
+		case framework <- msg:
+		case <-st.cfg.Done:
+		}
 	} else {
-		forwardEnvelope(logLevel, msg, RoutingTable, self, networkAddress, neighbours, NMap, raw, RouterIPAddress)
+		forwardEnvelope(logLevel, msg, RoutingTable, self, networkAddress, neighbours, NMap, raw, RouterIPAddress, st)
 	}
 }
 
 // ---- TopologyUpdate ----
 
-// updateNeighboursSlidingWindow ... Create the link between routers in the routing DVRTable
-func updateNeighboursSlidingWindow(logLevel string, msg TopologyUpdate, networkAddress IPv4, RoutingTable DVRTable) {
+// updateNeighboursSlidingWindow ... Create the link between routers in the routing DVRTable. Once a router has
+// advertised its own links they take precedence over the paths of updates, which may be older: its entries aren't
+// replaced, and a link missing from its advertisement has been withdrawn, so isn't learned from a path in either
+// direction
+func updateNeighboursSlidingWindow(logLevel string, msg TopologyUpdate, networkAddress IPv4, RoutingTable *DVRTable, announced map[RouterId]bool, now time.Time) {
+	withdrawn := func(from RouterId, to RouterId) bool {
+		_, ok := RoutingTable.Get(from, to)
+		return announced[from] && !ok
+	}
 	for i := 0; i < len(msg.Path)-1; i++ {
+		a, b := msg.Path[i], msg.Path[i+1]
+		if withdrawn(a, b) || withdrawn(b, a) {
+			if logLevel == "verbose" {
+				log.Printf("[%v] Ignoring router link [%v] <=> [%v] withdrawn since the update was sent",
+					networkAddress.toString(false),
+					a,
+					b)
+			}
+			continue
+		}
 		if logLevel == "verbose" {
 			log.Printf("[%v] Updating DVRTable with router link [%v] <=> [%v]",
 				networkAddress.toString(false),
-				msg.Path[i],
-				msg.Path[i+1])
+				a,
+				b)
+		}
+		// Dual pairings, each direction with its own cost
+		forwards, backwards := 1.0, 1.0
+		if i < len(msg.Costs) {
+			forwards, backwards = msg.Costs[i][0], msg.Costs[i][1]
+		}
+		if !announced[a] {
+			RoutingTable.Put(a, b, Entry{forwards, msg.Path[0], now, msg.Sequence})
+		}
+		if !announced[b] {
+			RoutingTable.Put(b, a, Entry{backwards, msg.Path[0], now, msg.Sequence})
+		}
+	}
+}
+
+// updateOriginLinks ... Replace the origin's links in the routing DVRTable with those it advertised
+func updateOriginLinks(logLevel string, msg TopologyUpdate, networkAddress IPv4, RoutingTable *DVRTable, now time.Time) {
+	origin := msg.Path[0]
+	for _, n := range RoutingTable.Neighbours(origin) {
+		if _, ok := msg.Links[n]; !ok {
+			if logLevel == "verbose" {
+				log.Printf("[%v] Removing router link [%v] => [%v] no longer advertised",
+					networkAddress.toString(false),
+					origin,
+					n)
+			}
+			RoutingTable.RemoveLink(origin, n)
 		}
-		// Dual pairings, ensure as a symmetric matrix (A^T = A)
-		RoutingTable.put(msg.Path[i], msg.Path[i+1], 1)
-		RoutingTable.put(msg.Path[i+1], msg.Path[i], 1)
+	}
+	for n, cost := range msg.Links {
+		RoutingTable.Put(origin, n, Entry{cost, origin, now, msg.Sequence})
 	}
 }
 
 // forwardPathMsg ... Push the message to all neighbours to mirror the path through the network
-func forwardPathMsg(logLevel string, msg TopologyUpdate, self RouterId, networkAddress IPv4, neighbours []chan<- interface{}, RouterIPAddress IPv4, NMap NeighbourMap) {
-	msg.Path = append(msg.Path, self)
-	validToSend := NMap.getAllNotIn(msg.Path)
+func forwardPathMsg(logLevel string, msg TopologyUpdate, self RouterId, networkAddress IPv4, neighbours []chan<- interface{}, RouterIPAddress IPv4, NMap NeighbourMap, st *routerState) {
+	last := msg.Path[len(msg.Path)-1]
+	// Copy before appending, the path and costs are shared with the other neighbours' copies of the update
+	msg.Path = append(append(make(Routers, 0, len(msg.Path)+1), msg.Path...), self)
+	msg.Costs = append(append(make([][2]float64, 0, len(msg.Costs)+1), msg.Costs...),
+		[2]float64{st.costs.cost(last, self), st.costs.cost(self, last)})
+	validToSend := NMap.getAllNotIn(msg.Path, len(neighbours))
 	if len(validToSend) == 0 {
 		if logLevel != "none" {
 			log.Printf("[%s] Topology update invalidated [%v]",
@@ -174,44 +358,103 @@ func forwardPathMsg(logLevel string, msg TopologyUpdate, self RouterId, networkA
 				neighbours[i],
 				msg.ID)
 		}
-		// Forward the message to all neighbours
-		go func(ns *[]chan<- interface{}, idx int, ms TopologyUpdate) {
-			(*ns)[idx] <- ms
-		}(&neighbours, i, msg)
+		// Forward the message to all neighbours, taking the channel now as a link coming up may replace it
+		go sendOrStop(neighbours[i], msg, st.cfg.Done)
 	}
 }
 
-func processPathMsg(logLevel string, self RouterId, networkAddress IPv4, RouterIPAddress IPv4, msg TopologyUpdate, RoutingTable DVRTable, neighbours []chan<- interface{}, NMap NeighbourMap) {
+func processPathMsg(logLevel string, self RouterId, networkAddress IPv4, RouterIPAddress IPv4, msg TopologyUpdate, RoutingTable *DVRTable, neighbours []chan<- interface{}, NMap NeighbourMap, st *routerState) {
 	if logLevel == "verbose" {
 		log.Printf("[%v] Processing topology update [%v] <- {%v}",
 			networkAddress.toString(false),
 			msg.ID,
 			msg.IP.toString(false))
 	}
+	origin := msg.Path[0]
+	if origin == self {
+		return
+	}
+	now := time.Now()
 	if len(msg.Path) < 2 {
 		// Single router ID in the TopologyUpdate, update the pairing with self ID
-		RoutingTable.put(self, msg.Path[0], 1)
-		RoutingTable.put(msg.Path[0], self, 1)
-	} else {
-		// Multiple router IDs in the TopologyUpdate, update as sliding window pairings
-		updateNeighboursSlidingWindow(logLevel, msg, networkAddress, RoutingTable)
+		_, known := RoutingTable.Get(self, origin)
+		RoutingTable.Put(self, origin, Entry{st.costs.cost(self, origin), origin, now, msg.Sequence})
+		RoutingTable.Put(origin, self, Entry{st.costs.cost(origin, self), origin, now, msg.Sequence})
+		if !known {
+			// A new direct link, tell the rest of the network about it
+			advertiseLinks(logLevel, self, networkAddress, RouterIPAddress, RoutingTable, neighbours, st)
+		}
 	}
-	if !msg.Path.contains(self) {
-		// If this is the first time the message has visited here, re-send to neighbours
-		forwardPathMsg(logLevel, msg, self, networkAddress, neighbours, RouterIPAddress, NMap)
-	} else {
+	if msg.Sequence <= st.sequences[origin] {
 		if logLevel == "verbose" {
-			log.Printf("[%s] Topology update invalidated by cyclic traversal: [%v] %v",
+			log.Printf("[%s] Topology update invalidated, already seen sequence %v from [%v]: [%v] %v",
 				networkAddress.toString(false),
+				msg.Sequence,
+				origin,
 				msg.ID,
 				msg.Path)
 		}
+		return
+	}
+	st.sequences[origin] = msg.Sequence
+	// Update as sliding window pairings along the path, then with the origin's own links
+	updateNeighboursSlidingWindow(logLevel, msg, networkAddress, RoutingTable, st.announced, now)
+	if msg.Links != nil {
+		updateOriginLinks(logLevel, msg, networkAddress, RoutingTable, now)
+		st.announced[origin] = true
+		RoutingTable.SetGroups(origin, msg.Groups)
+		RoutingTable.SetPrefixes(origin, msg.Prefixes)
 	}
+	// If this is the first time the sequence has visited here, re-send to neighbours
+	forwardPathMsg(logLevel, msg, self, networkAddress, neighbours, RouterIPAddress, NMap, st)
 }
 
 // #### ROUTER IMPLEMENTATION ####
 
-func sendTopologyUpdate(logLevel string, networkAddress IPv4, newID UUID, nextHost IPv4, CurrPath Routers, neighbour chan<- interface{}) {
+// routerState ... A router's configuration and the state its features keep between messages
+type routerState struct {
+	cfg       Config
+	costs     Costs
+	sequences originSequences
+	announced map[RouterId]bool // Origins whose own links have been received, taking precedence over update paths
+	balance   *balancer
+	fib       *forwardingTable
+	algorithm routingAlgorithm
+	labels    *labelTable
+	trees     *sourceTrees
+	aliases   aliasSet
+	rng       *rand.Rand
+}
+
+// newRouterState ... Empty state for a router configured by {cfg}, its links costing as given by {costs}
+func newRouterState(cfg Config, costs Costs) *routerState {
+	if cfg.Seed == 0 {
+		cfg.Seed = time.Now().UnixNano()
+	}
+	return &routerState{
+		cfg:       cfg,
+		costs:     costs,
+		sequences: make(originSequences),
+		announced: make(map[RouterId]bool),
+		balance:   newBalancer(cfg.Multipath),
+		fib:       newForwardingTable(cfg),
+		algorithm: newRoutingAlgorithm(cfg),
+		labels:    newLabelTable(cfg),
+		trees:     &sourceTrees{},
+		aliases:   make(aliasSet),
+		rng:       rand.New(rand.NewSource(cfg.Seed)),
+	}
+}
+
+// sendOrStop ... Place {msg} on the channel {ns}, giving up should the network be stopped by closing {done} first
+func sendOrStop(ns chan<- interface{}, msg interface{}, done <-chan struct{}) {
+	select {
+	case ns <- msg:
+	case <-done:
+	}
+}
+
+func sendTopologyUpdate(logLevel string, networkAddress IPv4, newID UUID, nextHost IPv4, CurrPath Routers, sequence uint64, links map[RouterId]float64, groups []GroupId, prefixes []IPv4, neighbour chan<- interface{}, done <-chan struct{}) {
 	if logLevel != "none" {
 		log.Printf("[%v] Sending local topology update... [%v] -> {%v}",
 			networkAddress.toString(false),
@@ -219,17 +462,33 @@ func sendTopologyUpdate(logLevel string, networkAddress IPv4, newID UUID, nextHo
 			nextHost.toString(false))
 	}
 	// Update the neighbours with pathing and address info
-	go func(ns *chan<- interface{}) {
-		(*ns) <- TopologyUpdate{
-			ID:   newID,
-			IP:   networkAddress,
-			Path: CurrPath,
-		}
-	}(&neighbour)
+	go sendOrStop(neighbour, TopologyUpdate{
+		ID:       newID,
+		IP:       networkAddress,
+		Path:     CurrPath,
+		Sequence: sequence,
+		Links:    links,
+		Groups:   groups,
+		Prefixes: prefixes,
+	}, done)
+}
+
+// advertiseLinks ... Send the router's direct links to every neighbour under a new sequence number
+func advertiseLinks(logLevel string, self RouterId, networkAddress IPv4, RouterIPAddress IPv4, RoutingTable *DVRTable, neighbours []chan<- interface{}, st *routerState) {
+	st.sequences[self]++
+	links := RoutingTable.Links(self)
+	groups := RoutingTable.Groups(self)
+	prefixes := RoutingTable.Prefixes(self)
+	_, nextHost := RouterIPAddress.firstHostID()
+	for _, n := range neighbours {
+		newID, _ := uuid4()
+		sendTopologyUpdate(logLevel, networkAddress, newID, nextHost, Routers{self}, st.sequences[self], links, groups, prefixes, n, st.cfg.Done)
+	}
 }
 
 // mapNetwork ... Start network mapping of connections via TopologyUpdate and update mapping of neighbour channels with NeighbourUpdate
-func mapNetwork(logLevel string, neighbours []chan<- interface{}, self RouterId, nextHost IPv4, networkAddress IPv4, incoming <-chan interface{}) {
+func mapNetwork(logLevel string, neighbours []chan<- interface{}, self RouterId, nextHost IPv4, networkAddress IPv4, incoming <-chan interface{}, st *routerState) {
+	st.sequences[self]++
 	for i, n := range neighbours {
 		CurrPath := make(Routers, 0)
 		CurrPath = append(CurrPath, self)
@@ -245,14 +504,9 @@ func mapNetwork(logLevel string, neighbours []chan<- interface{}, self RouterId,
 				incoming,
 				n)
 		}
-		go func(selfID RouterId, ns chan<- interface{}, next <-chan interface{}) {
-			ns <- NeighbourUpdate{
-				selfID,
-				next,
-			}
-		}(self, n, incoming)
+		go sendOrStop(n, NeighbourUpdate{self, incoming}, st.cfg.Done)
 
-		sendTopologyUpdate(logLevel, networkAddress, newID, nextHost, CurrPath, n)
+		sendTopologyUpdate(logLevel, networkAddress, newID, nextHost, CurrPath, st.sequences[self], nil, nil, nil, n, st.cfg.Done)
 	}
 }
 
@@ -263,11 +517,25 @@ func mapNetwork(logLevel string, neighbours []chan<- interface{}, self RouterId,
 // - Dyamic shortest path
 // - Support for dropouts with periodic updates
 func Router(self RouterId, incoming <-chan interface{}, neighbours []chan<- interface{}, framework chan<- Envelope, logLevel string) {
-	// Assign a new local network IP with subnet range poer of 2 encapsulating all neighbours
-	RouterIPAddress := randomIPv4FromSubetSize(len(neighbours) + 1)
+	RouterWithConfig(self, incoming, neighbours, framework, Config{LogLevel: logLevel})
+}
+
+// RouterWithConfig ... Router with the options of {cfg}, see Config for what each enables. Every link costs 1
+func RouterWithConfig(self RouterId, incoming <-chan interface{}, neighbours []chan<- interface{}, framework chan<- Envelope, cfg Config) {
+	router(self, incoming, neighbours, framework, cfg, nil)
+}
+
+// router ... Router with the options of {cfg}, its links costing as given by {costs}
+func router(self RouterId, incoming <-chan interface{}, neighbours []chan<- interface{}, framework chan<- Envelope, cfg Config, costs Costs) {
+	logLevel := cfg.LogLevel
+	st := newRouterState(cfg, costs)
+	RouterIPAddress := initialAddress(self, len(neighbours), cfg, st.rng)
 	_, networkAddress := RouterIPAddress.networkID()
-	RoutingTable := make(DVRTable, 0)
+	RoutingTable := NewDVRTable()
 	NMap := make(NeighbourMap, len(neighbours))
+	// Advertise the router's own address and the block it numbers its neighbours from
+	RoutingTable.SetPrefixes(self, st.aliases.prefixes(RouterIPAddress))
+	dead := false
 
 	if logLevel == "verbose" {
 		log.Printf("[HOST: %v] -> Assigning CIDR block %v {Addresses: %v}",
@@ -278,7 +546,7 @@ func Router(self RouterId, incoming <-chan interface{}, neighbours []chan<- inte
 
 	_, nextHost := RouterIPAddress.firstHostID()
 
-	mapNetwork(logLevel, neighbours, self, nextHost, networkAddress, incoming)
+	mapNetwork(logLevel, neighbours, self, nextHost, networkAddress, incoming, st)
 
 	if logLevel == "verbose" {
 		log.Printf("[%v] Sent local topology update to %v neighbours",
@@ -288,14 +556,59 @@ func Router(self RouterId, incoming <-chan interface{}, neighbours []chan<- inte
 
 	for {
 		select {
+		case <-cfg.Done:
+			return
 		case raw := <-incoming:
+			if dead {
+				// Keep draining the channel so neighbours never block, answering only state requests
+				if msg, ok := raw.(StateRequest); ok {
+					msg.Reply <- snapshot(self, RouterIPAddress, networkAddress, RoutingTable, NMap, st)
+				}
+				continue
+			}
+			if len(st.aliases) > 0 && st.aliases.expire(time.Now()) {
+				RoutingTable.SetPrefixes(self, st.aliases.prefixes(RouterIPAddress))
+				advertiseLinks(logLevel, self, networkAddress, RouterIPAddress, RoutingTable, neighbours, st)
+			}
 			switch msg := raw.(type) {
 			case Envelope:
-				processEnvelope(logLevel, msg, self, framework, networkAddress, incoming, raw, RoutingTable, neighbours, NMap, RouterIPAddress)
+				if msg.Hops == 0 {
+					// Just injected, so latency excludes the time the sender waited for this router
+					msg.Injected = time.Now()
+				}
+				processEnvelope(logLevel, msg, self, framework, networkAddress, incoming, raw, RoutingTable, neighbours, NMap, RouterIPAddress, st)
 			case NeighbourUpdate:
 				processNeighbourUpdate(logLevel, msg, neighbours, networkAddress, NMap)
 			case TopologyUpdate:
-				processPathMsg(logLevel, self, networkAddress, RouterIPAddress, msg, RoutingTable, neighbours, NMap)
+				if st.fib.down[msg.Path[len(msg.Path)-1]] {
+					// Sent over a link that has since gone down, so lost with it
+					continue
+				}
+				processPathMsg(logLevel, self, networkAddress, RouterIPAddress, msg, RoutingTable, neighbours, NMap, st)
+				if cfg.Allocation == "negotiated" && msg.Links != nil {
+					RouterIPAddress, networkAddress = negotiateAddress(logLevel, self, networkAddress, RouterIPAddress, RoutingTable, neighbours, st)
+				}
+			case StateRequest:
+				msg.Reply <- snapshot(self, RouterIPAddress, networkAddress, RoutingTable, NMap, st)
+			case Dropout:
+				processDropout(logLevel, self, networkAddress, neighbours, cfg.Done)
+				dead = true
+			case LabelRequest:
+				processLabelRequest(logLevel, msg, networkAddress, neighbours, NMap, st)
+			case LabelMapping:
+				processLabelMapping(logLevel, msg, networkAddress, neighbours, NMap, st)
+			case LabelWithdraw:
+				processLabelWithdraw(logLevel, msg, networkAddress, neighbours, NMap, st)
+			case Join:
+				processMembership(logLevel, msg.Group, true, self, networkAddress, RouterIPAddress, RoutingTable, neighbours, st)
+			case Leave:
+				processMembership(logLevel, msg.Group, false, self, networkAddress, RouterIPAddress, RoutingTable, neighbours, st)
+			case LinkDown:
+				processLinkDown(logLevel, msg, self, networkAddress, RouterIPAddress, RoutingTable, neighbours, st)
+				RouterIPAddress, networkAddress = readdress(logLevel, self, networkAddress, RouterIPAddress, RoutingTable, neighbours, NMap, st)
+			case LinkUp:
+				neighbours = processLinkUp(logLevel, msg, self, networkAddress, RouterIPAddress, RoutingTable, neighbours, NMap, st)
+				RouterIPAddress, networkAddress = readdress(logLevel, self, networkAddress, RouterIPAddress, RoutingTable, neighbours, NMap, st)
 			default:
 				log.Printf("[%v] received unexpected message %g\n", self, msg)
 			}
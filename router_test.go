@@ -0,0 +1,199 @@
+package routers
+
+import (
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// testNetwork ... Routers of a template, each with every link of it in its table, driven one message at a time by
+// the test rather than by their goroutines, so that where each router sends what can be followed
+type testNetwork struct {
+	tables     []*DVRTable
+	states     []*routerState
+	inputs     []chan interface{}
+	neighbours [][]chan<- interface{}
+	NMaps      []NeighbourMap
+	framework  chan Envelope
+}
+
+// queued ... A message waiting on a router's input
+type queued struct {
+	to  RouterId
+	msg interface{}
+}
+
+func newTestNetwork(w WeightedTemplate, cfg Config) *testNetwork {
+	n := &testNetwork{framework: make(chan Envelope, 64)}
+	for range w.Template {
+		n.inputs = append(n.inputs, make(chan interface{}, 64))
+	}
+	for _, ids := range w.Template {
+		neighbours := make([]chan<- interface{}, len(ids))
+		NMap := make(NeighbourMap, len(ids))
+		for i, neighbour := range ids {
+			neighbours[i] = n.inputs[neighbour]
+			NMap[neighbour] = i
+		}
+		n.tables = append(n.tables, TableFromTemplate(w.Template, w.Costs))
+		n.states = append(n.states, newRouterState(cfg, w.Costs))
+		n.neighbours = append(n.neighbours, neighbours)
+		n.NMaps = append(n.NMaps, NMap)
+	}
+	return n
+}
+
+// inject ... Hand the envelope to router {at} and follow every message sent on from it until none remain,
+// returning the routers that handled a copy of the envelope, in order, and the copies delivered to the framework
+func (n *testNetwork) inject(at RouterId, msg Envelope) (Path, []Envelope) {
+	visited := make(Path, 0)
+	pending := []queued{{at, msg}}
+	for steps := 0; len(pending) > 0 && steps < 1000; steps++ {
+		next := pending[0]
+		pending = pending[1:]
+		if _, ok := next.msg.(Envelope); ok {
+			visited = append(visited, next.to)
+		}
+		pending = append(pending, n.handle(next.to, next.msg)...)
+	}
+	delivered := make([]Envelope, 0)
+	for len(n.framework) > 0 {
+		delivered = append(delivered, <-n.framework)
+	}
+	return visited, delivered
+}
+
+// handle ... Process the message at router {self} as its goroutine would, returning what it sent once every send
+// it started has finished
+func (n *testNetwork) handle(self RouterId, raw interface{}) []queued {
+	running := runtime.NumGoroutine()
+	st, table, neighbours, NMap := n.states[self], n.tables[self], n.neighbours[self], n.NMaps[self]
+	switch msg := raw.(type) {
+	case Envelope:
+		processEnvelope("none", msg, self, n.framework, IPv4{}, nil, raw, table, neighbours, NMap, IPv4{}, st)
+	case LabelRequest:
+		processLabelRequest("none", msg, IPv4{}, neighbours, NMap, st)
+	case LabelMapping:
+		processLabelMapping("none", msg, IPv4{}, neighbours, NMap, st)
+	case LabelWithdraw:
+		processLabelWithdraw("none", msg, IPv4{}, neighbours, NMap, st)
+	case LinkDown:
+		processLinkDown("none", msg, self, IPv4{}, IPv4{}, table, neighbours, st)
+	}
+	for deadline := time.Now().Add(time.Second); runtime.NumGoroutine() > running && time.Now().Before(deadline); {
+		time.Sleep(100 * time.Microsecond)
+	}
+	sent := make([]queued, 0)
+	for id, input := range n.inputs {
+		for len(input) > 0 {
+			if msg := <-input; !isTopologyUpdate(msg) {
+				sent = append(sent, queued{RouterId(id), msg})
+			}
+		}
+	}
+	return sent
+}
+
+func isTopologyUpdate(msg interface{}) bool {
+	_, ok := msg.(TopologyUpdate)
+	return ok
+}
+
+// ladder ... 0-1-2 above 3-4-5, each linked to the one below
+var ladder = WeightedTemplate{Template: Template{{1, 3}, {0, 2, 4}, {1, 5}, {0, 4}, {1, 3, 5}, {2, 4}}}
+
+func TestSourceRouting(t *testing.T) {
+	tests := []struct {
+		name     string
+		dest     RouterId
+		segments []RouterId
+		strict   bool
+		want     Path
+	}{
+		{"least cost", 5, nil, false, Path{0, 1, 2, 5}},
+		{"strict", 5, []RouterId{3, 4}, true, Path{0, 3, 4, 5}},
+		{"strict ending beside the destination", 2, []RouterId{3, 4, 5}, true, Path{0, 3, 4, 5, 2}},
+		{"strict broken", 5, []RouterId{4}, true, Path{0, 1, 2, 5}},
+		{"loose waypoint", 2, []RouterId{3}, false, Path{0, 3, 0, 1, 2}},
+		{"loose waypoints in order", 3, []RouterId{4, 2}, false, Path{0, 1, 4, 1, 2, 1, 0, 3}},
+		{"waypoint at the source", 2, []RouterId{0}, false, Path{0, 1, 2}},
+		{"waypoint at the destination", 5, []RouterId{5}, false, Path{0, 1, 2, 5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := newTestNetwork(ladder, Config{})
+			visited, delivered := n.inject(0, Envelope{Source: 0, Dest: tt.dest, Segments: tt.segments, Strict: tt.strict})
+			if !reflect.DeepEqual(visited, tt.want) {
+				t.Errorf("routed %v, want %v", visited, tt.want)
+			}
+			if len(delivered) != 1 || delivered[0].Dest != tt.dest || delivered[0].Hops != uint(len(tt.want)-1) {
+				t.Errorf("delivered %+v, want one envelope at [%v] after %v hops", delivered, tt.dest, len(tt.want)-1)
+			}
+		})
+	}
+}
+
+func TestTopologyUpdatePaths(t *testing.T) {
+	// Each update reaches router 0 last through router 9
+	discovery := TopologyUpdate{Path: Routers{1, 2, 3, 9}, Sequence: 1}
+	tests := []struct {
+		name    string
+		updates []TopologyUpdate
+		present map[Link]float64
+		absent  []Link
+	}{
+		{
+			"learned from the path",
+			[]TopologyUpdate{discovery},
+			map[Link]float64{{1, 2}: 1, {2, 1}: 1, {2, 3}: 1, {3, 2}: 1},
+			nil,
+		},
+		{
+			"withdrawn by the near end",
+			[]TopologyUpdate{{Path: Routers{2, 9}, Sequence: 5, Links: map[RouterId]float64{1: 1, 9: 1}}, discovery},
+			map[Link]float64{{1, 2}: 1, {2, 1}: 1},
+			[]Link{{2, 3}, {3, 2}},
+		},
+		{
+			"withdrawn by the far end",
+			[]TopologyUpdate{{Path: Routers{3, 9}, Sequence: 5, Links: map[RouterId]float64{9: 1}}, discovery},
+			map[Link]float64{{1, 2}: 1, {2, 1}: 1},
+			[]Link{{2, 3}, {3, 2}},
+		},
+		{
+			"advertised cost kept",
+			[]TopologyUpdate{{Path: Routers{2, 9}, Sequence: 5, Links: map[RouterId]float64{1: 1, 3: 4, 9: 1}}, discovery},
+			map[Link]float64{{2, 3}: 4, {3, 2}: 1},
+			nil,
+		},
+		{
+			"relearned once advertised again",
+			[]TopologyUpdate{
+				{Path: Routers{2, 9}, Sequence: 5, Links: map[RouterId]float64{1: 1, 9: 1}},
+				discovery,
+				{Path: Routers{2, 9}, Sequence: 6, Links: map[RouterId]float64{1: 1, 3: 1, 9: 1}},
+			},
+			map[Link]float64{{2, 3}: 1},
+			nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			table, st := NewDVRTable(), newRouterState(Config{}, nil)
+			for _, msg := range tt.updates {
+				processPathMsg("none", 0, IPv4{}, IPv4{}, msg, table, nil, NeighbourMap{}, st)
+			}
+			for l, want := range tt.present {
+				if cost, ok := table.Cost(l.From, l.To); !ok || cost != want {
+					t.Errorf("link %v has cost %v (present %v), want %v", l, cost, ok, want)
+				}
+			}
+			for _, l := range tt.absent {
+				if _, ok := table.Get(l.From, l.To); ok {
+					t.Errorf("link %v learned, want it withdrawn", l)
+				}
+			}
+		})
+	}
+}
@@ -2,6 +2,8 @@ package routers
 
 import (
 	"fmt"
+	"math/rand"
+	"time"
 )
 
 type RouterId uint
@@ -9,9 +11,43 @@ type RouterId uint
 type Template [][]RouterId
 
 type Envelope struct {
-	Dest    RouterId
-	Hops    uint
-	Message interface{}
+	Source    RouterId // Router the envelope was injected at
+	Dest      RouterId
+	FlowLabel uint32 // Distinguishes flows between the same routers for multipath hashing
+	Hops      uint
+	Cost      float64 // Total cost of the links traversed
+	Message   interface{}
+	Injected  time.Time // Set by the router the envelope is injected at on accepting it
+	Delivered time.Time // Set by the destination router when handed to the framework
+	Rerouted  bool      // Set once a router fast reroutes the envelope around a failed next hop
+	// Source route, the routers to visit in order on the way to the destination. Each is removed once reached
+	Segments []RouterId
+	// Strict source routes list every router on the path, each a neighbour of the last. Loose ones are waypoints
+	// reached by the routers' own least cost paths
+	Strict bool
+	Labels []uint32 // Label stack, top last, pushed by the ingress of a label switched path
+	// Deliver a copy to every router, or to every member of Group when non-zero, rather than to Dest. Each copy
+	// handed to the framework has Dest set to the router delivering it and Source to the router it was injected at
+	Broadcast bool
+	Group     GroupId
+	// Deliver to the nearest member of the group when non-zero, Dest being set to it on the way. Anycast shares
+	// its groups with multicast, routers joining one to serve the other
+	Anycast GroupId
+	// Deliver to the router advertising the longest prefix holding this address when set, Dest being set to it on
+	// the way. Among routers advertising the same prefix the nearest is chosen, as for anycast
+	DestAddress IPv4
+
+	// Set once a routing algorithm hands the envelope to the routing table, which then takes it the rest of the way
+	// so that the two can't bounce it between them
+	tableRouted bool
+
+	// Geographic routing state, kept while the envelope is routed around a void by the right hand rule
+	perimeter bool
+	entry     [2]float64 // Where perimeter routing began
+	crossing  [2]float64 // Where the envelope last moved onto a face closer to the destination
+	firstEdge Link       // First link taken on the current face, only taken again if the destination is unreachable
+
+	previous RouterId // Router the envelope was last sent from, for perimeter routing and reverse path checks
 }
 
 func hasLink(routers []RouterId, id RouterId) bool {
@@ -23,7 +59,110 @@ func hasLink(routers []RouterId, id RouterId) bool {
 	return false
 }
 
-func MakeRouters(t Template, logLevel string, printCons bool) (in []chan<- interface{}, out <-chan Envelope) {
+// Link ... A directed link between two routers
+type Link struct {
+	From RouterId
+	To   RouterId
+}
+
+// Costs ... Cost of traversing each link, links without an entry cost 1
+type Costs map[Link]float64
+
+// cost ... Cost of the link from {from} to {to}
+func (c Costs) cost(from RouterId, to RouterId) float64 {
+	if v, ok := c[Link{from, to}]; ok {
+		return v
+	}
+	return 1
+}
+
+// Config ... Options for building a network of routers
+type Config struct {
+	LogLevel         string // none, normal or verbose
+	PrintConnections bool   // Print the adjacency matrix of the template
+	Symmetrise       bool   // Repair one sided links, self loops and duplicates rather than rejecting the template
+	Multipath        string // Spreading over equal cost next hops: none, flow (hashed) or packet (round-robin)
+	// Precompute loop free alternates and switch to them as soon as a neighbour fails
+	FastReroute bool
+	// Time a router keeps forwarding on its old routes after its routing table changes, as if recomputing them
+	ConvergenceDelay time.Duration
+	// How envelopes are routed: table (default), dimension_order or adaptive (requiring Grid) or geographic
+	// (requiring Positions)
+	Routing   string
+	Grid      *Grid
+	Positions [][2]float64 // X, Y position of each router, indexed by RouterId
+	// Forward by label over paths set up by each envelope's ingress, rather than looking up every hop
+	LabelSwitching bool
+	// How each router's CIDR block is assigned: random (default), sequential (carved from Supernet up front, into
+	// Blocks unless given) or negotiated (claimed by each router from Supernet, conflicts resolved as routers learn
+	// of each other's blocks)
+	Allocation string
+	Supernet   IPv4   // 10.0.0.0/8 when unset
+	Blocks     []IPv4 // Address of each router with the CIDR prefix of its block, indexed by RouterId
+	// How a router renumbers once links to its neighbours come up or go down: none (default), static (keeping its
+	// address, the block resized around it), dynamic (moving to a block of the new size) or dns (as dynamic, still
+	// answering to the old address for a while)
+	Readdressing string
+	// Time a router renumbered under the dns mode keeps answering to its old address, a second when unset
+	AliasHold time.Duration
+	// Seed of the routers' random choices. Each router draws from its own source, seeded in RouterId order from
+	// one seeded by it, so no source is shared between router goroutines. Seeded from the clock when zero
+	Seed int64
+	// Closing Done stops every router, abandoning the messages still being sent between them and to the framework
+	Done <-chan struct{}
+}
+
+func MakeRouters(t Template, logLevel string, printCons bool) (in []chan<- interface{}, out <-chan Envelope, err error) {
+	return MakeRoutersWithConfig(t, Config{LogLevel: logLevel, PrintConnections: printCons})
+}
+
+// MakeRoutersWithConfig ... Validate the template and start a router for each of its entries, every link costing 1
+func MakeRoutersWithConfig(t Template, cfg Config) (in []chan<- interface{}, out <-chan Envelope, err error) {
+	return MakeWeightedRouters(WeightedTemplate{Template: t}, cfg)
+}
+
+// MakeWeightedRouters ... Validate the template and its costs and start a router for each of its entries, choosing
+// paths of least cost
+func MakeWeightedRouters(w WeightedTemplate, cfg Config) (in []chan<- interface{}, out <-chan Envelope, err error) {
+	if cfg.Symmetrise {
+		w = w.Symmetrise()
+	}
+	if problems := w.Validate(); problems != nil {
+		return nil, nil, problems
+	}
+	t := w.Template
+	if err := validMultipath(cfg.Multipath); err != nil {
+		return nil, nil, err
+	}
+	if err := validRouting(cfg, t); err != nil {
+		return nil, nil, err
+	}
+	if err := validAllocation(cfg); err != nil {
+		return nil, nil, err
+	}
+	if err := validReaddressing(cfg); err != nil {
+		return nil, nil, err
+	}
+	if cfg.Allocation == "sequential" && cfg.Blocks == nil {
+		if cfg.Blocks, err = AllocateBlocks(supernetOf(cfg), t); err != nil {
+			return nil, nil, err
+		}
+	}
+	if cfg.Allocation == "negotiated" {
+		// Only check the supernet can hold every block, the routers claim their own
+		if _, err := AllocateBlocks(supernetOf(cfg), t); err != nil {
+			return nil, nil, err
+		}
+	}
+	if cfg.Allocation == "sequential" && len(cfg.Blocks) != len(t) {
+		return nil, nil, fmt.Errorf("sequential allocation requires blocks for %v routers, have %v", len(t), len(cfg.Blocks))
+	}
+	printCons := cfg.PrintConnections
+	if cfg.Seed == 0 {
+		cfg.Seed = time.Now().UnixNano()
+	}
+	seeds := rand.New(rand.NewSource(cfg.Seed))
+
 	channels := make([]chan interface{}, len(t))
 	framework := make(chan Envelope)
 
@@ -81,7 +220,9 @@ func MakeRouters(t Template, logLevel string, printCons bool) (in []chan<- inter
 			neighbours[i] = channels[id]
 		}
 
-		go Router(RouterId(routerId), channels[routerId], neighbours, framework, logLevel)
+		routerCfg := cfg
+		routerCfg.Seed = seeds.Int63()
+		go router(RouterId(routerId), channels[routerId], neighbours, framework, routerCfg, w.Costs)
 	}
 
 	return
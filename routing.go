@@ -0,0 +1,60 @@
+package routers
+
+import "fmt"
+
+// routingModes ... Supported values of Config.Routing
+var routingModes = []string{"", "table", "dimension_order", "adaptive", "geographic"}
+
+// routingAlgorithm ... Chooses an envelope's next hop without consulting the routing table. Reporting false
+// hands the envelope back to the table
+type routingAlgorithm interface {
+	name() string
+	nextHop(ctx hopContext, msg *Envelope) (RouterId, bool)
+}
+
+// hopContext ... The router state a routing algorithm may consult
+type hopContext struct {
+	self    RouterId
+	NMap    NeighbourMap
+	balance *balancer
+	fib     *forwardingTable
+}
+
+// usable ... Check the neighbour's channel is known and it hasn't failed
+func (c hopContext) usable(id RouterId) bool {
+	_, ok := c.NMap[id]
+	return ok && !c.fib.down[id]
+}
+
+// newRoutingAlgorithm ... The algorithm for the configured routing mode, nil when routing by table
+func newRoutingAlgorithm(cfg Config) routingAlgorithm {
+	switch cfg.Routing {
+	case "dimension_order", "adaptive":
+		return newGridRouting(*cfg.Grid, cfg.Routing == "adaptive")
+	case "geographic":
+		return &geographicRouting{positions: cfg.Positions}
+	default:
+		return nil
+	}
+}
+
+// validRouting ... Check the routing mode is supported and has what it needs for the routers of {t}
+func validRouting(cfg Config, t Template) error {
+	supported := false
+	for _, m := range routingModes {
+		supported = supported || m == cfg.Routing
+	}
+	if !supported {
+		return fmt.Errorf("unsupported routing mode %q (expected table, dimension_order, adaptive or geographic)", cfg.Routing)
+	}
+	if cfg.Routing == "dimension_order" || cfg.Routing == "adaptive" {
+		if cfg.Grid == nil {
+			return fmt.Errorf("%s routing requires grid coordinates", cfg.Routing)
+		}
+		return cfg.Grid.validate(t)
+	}
+	if cfg.Routing == "geographic" && len(cfg.Positions) != len(t) {
+		return fmt.Errorf("geographic routing requires positions for %v routers, have %v", len(t), len(cfg.Positions))
+	}
+	return nil
+}
@@ -1,6 +1,11 @@
 package routers
 
-import "sync"
+import (
+	"container/heap"
+	"math"
+	"sort"
+	"sync"
+)
 
 // Path ... Ordered record of pathing traversals
 type Path []RouterId
@@ -15,9 +20,10 @@ func (p Path) hasVisited(id RouterId) bool {
 	return false
 }
 
-// AsyncShortestPath ... Finds the shortest path through a network with Dijkstra's algorithm
-func AsyncShortestPath(table DVRTable, start RouterId, end RouterId, path Path) []RouterId {
-	if table.getRow(start) == nil || table.getRow(end) == nil {
+// AsyncShortestPath ... Finds the path of fewest hops through a network by exploring every loop free path concurrently.
+// Link costs are ignored, see ShortestPath for least cost routing
+func AsyncShortestPath(table *DVRTable, start RouterId, end RouterId, path Path) []RouterId {
+	if len(table.Neighbours(start)) == 0 || len(table.Neighbours(end)) == 0 {
 		return path
 	}
 	path = append(path, start)
@@ -28,16 +34,16 @@ func AsyncShortestPath(table DVRTable, start RouterId, end RouterId, path Path)
 	shortest := make([]RouterId, 0)
 	// New WaitGroup to prevent exit until all goroutines terminate
 	var wg sync.WaitGroup
-	for idx, con := range table.getRow(start) {
+	for idx, con := range table.Links(start) {
 		if path.hasVisited(idx) {
 			continue
 		}
-		if con.(int) == 0 {
+		if con == 0 {
 			continue
 		}
 		wg.Add(1)
 		// Explore the neighbouring connections
-		go func(g DVRTable, s RouterId, e RouterId, p Path, sp *[]RouterId, wg *sync.WaitGroup) {
+		go func(g *DVRTable, s RouterId, e RouterId, p Path, sp *[]RouterId, wg *sync.WaitGroup) {
 			defer wg.Done()
 			newPath := AsyncShortestPath(g, s, e, p)
 			if len(newPath) <= 0 {
@@ -55,3 +61,137 @@ func AsyncShortestPath(table DVRTable, start RouterId, end RouterId, path Path)
 	wg.Wait()
 	return shortest
 }
+
+// ShortestPath ... Finds the least cost path through the learned network with Dijkstra's algorithm,
+// returning nil if {end} is unreachable
+func ShortestPath(table *DVRTable, start RouterId, end RouterId) (Path, float64) {
+	costs, parents := shortestPaths(table, start)
+	cost, ok := costs[end]
+	if !ok {
+		return nil, 0
+	}
+	path := Path{end}
+	for current := end; current != start; {
+		current = parents[current]
+		path = append(path, current)
+	}
+	// Reverse into start -> end order
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path, cost
+}
+
+// shortestPaths ... Dijkstra's algorithm from {start} over the whole table, returning the least cost to each
+// reachable router and its parent on that path. Equal cost parents are broken towards the lowest RouterId
+// so every router computing the same tree agrees on it
+func shortestPaths(table *DVRTable, start RouterId) (map[RouterId]float64, map[RouterId]RouterId) {
+	costs := map[RouterId]float64{start: 0}
+	parents := make(map[RouterId]RouterId)
+	done := make(map[RouterId]bool)
+	queue := &costQueue{{start, 0}}
+	for queue.Len() > 0 {
+		current := heap.Pop(queue).(costItem)
+		if done[current.id] {
+			continue
+		}
+		done[current.id] = true
+		for next, cost := range table.Links(current.id) {
+			if done[next] {
+				continue
+			}
+			total := current.cost + cost
+			known, ok := costs[next]
+			if !ok || total < known || (total == known && current.id < parents[next]) {
+				costs[next] = total
+				parents[next] = current.id
+				heap.Push(queue, costItem{next, total})
+			}
+		}
+	}
+	return costs, parents
+}
+
+// costEpsilon ... Tolerance when comparing summed link costs for equality
+const costEpsilon = 1e-9
+
+// EqualCostNextHops ... Every neighbour of {start} that lies on a least cost path to {end}, in ascending order,
+// along with that cost. Returns nil if {end} is unreachable or is {start}
+func EqualCostNextHops(table *DVRTable, start RouterId, end RouterId) ([]RouterId, float64) {
+	costs, firstHops := equalCostFirstHops(table, start)
+	cost, ok := costs[end]
+	if !ok {
+		return nil, 0
+	}
+	return firstHops[end], cost
+}
+
+// equalCostFirstHops ... Least cost to each reachable router from {start} and the set of first hops, in ascending
+// order, over all of the least cost paths to it
+func equalCostFirstHops(table *DVRTable, start RouterId) (map[RouterId]float64, map[RouterId][]RouterId) {
+	costs, _ := shortestPaths(table, start)
+	order := make([]RouterId, 0, len(costs))
+	for id := range costs {
+		order = append(order, id)
+	}
+	sort.Slice(order, func(i, j int) bool {
+		if costs[order[i]] != costs[order[j]] {
+			return costs[order[i]] < costs[order[j]]
+		}
+		return order[i] < order[j]
+	})
+	// Walk the least cost DAG outwards, each router inheriting the first hops of its least cost predecessors
+	sets := make(map[RouterId]map[RouterId]bool, len(costs))
+	for _, current := range order {
+		for next, cost := range table.Links(current) {
+			known, ok := costs[next]
+			if !ok || next == start || math.Abs(costs[current]+cost-known) > costEpsilon {
+				continue
+			}
+			if sets[next] == nil {
+				sets[next] = make(map[RouterId]bool)
+			}
+			if current == start {
+				sets[next][next] = true
+			}
+			for hop := range sets[current] {
+				sets[next][hop] = true
+			}
+		}
+	}
+	firstHops := make(map[RouterId][]RouterId, len(sets))
+	for id, set := range sets {
+		hops := make([]RouterId, 0, len(set))
+		for hop := range set {
+			hops = append(hops, hop)
+		}
+		sort.Slice(hops, func(i, j int) bool { return hops[i] < hops[j] })
+		firstHops[id] = hops
+	}
+	return costs, firstHops
+}
+
+// costItem ... A router and the cost to reach it, queued for Dijkstra's algorithm
+type costItem struct {
+	id   RouterId
+	cost float64
+}
+
+// costQueue ... Min-heap of costItem ordered by cost then RouterId
+type costQueue []costItem
+
+func (q costQueue) Len() int { return len(q) }
+func (q costQueue) Less(i, j int) bool {
+	if q[i].cost != q[j].cost {
+		return q[i].cost < q[j].cost
+	}
+	return q[i].id < q[j].id
+}
+func (q costQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *costQueue) Push(x interface{}) { *q = append(*q, x.(costItem)) }
+func (q *costQueue) Pop() interface{} {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
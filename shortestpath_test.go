@@ -0,0 +1,35 @@
+package routers
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestShortestPath(t *testing.T) {
+	// Square 0-1-2-3 with a diagonal from 0 to 2, and router 4 on its own
+	square := Template{{1, 3, 2}, {0, 2}, {1, 3, 0}, {2, 0}, {}}
+	tests := []struct {
+		name  string
+		costs Costs
+		start RouterId
+		end   RouterId
+		want  Path
+		cost  float64
+	}{
+		{"fewest hops without costs", nil, 0, 2, Path{0, 2}, 1},
+		{"expensive diagonal avoided", Costs{{0, 2}: 5}, 0, 2, Path{0, 1, 2}, 2},
+		{"costs are directional", Costs{{0, 2}: 5}, 2, 0, Path{2, 0}, 1},
+		{"equal cost broken to the lowest id", Costs{{0, 2}: 3}, 0, 2, Path{0, 1, 2}, 2},
+		{"cheap detour", Costs{{0, 1}: 4, {0, 2}: 4, {0, 3}: 0.5, {3, 2}: 0.5}, 0, 1, Path{0, 3, 2, 1}, 2},
+		{"to itself", nil, 3, 3, Path{3}, 0},
+		{"unreachable", nil, 0, 4, nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, cost := ShortestPath(TableFromTemplate(square, tt.costs), tt.start, tt.end)
+			if !reflect.DeepEqual(got, tt.want) || cost != tt.cost {
+				t.Errorf("ShortestPath() = %v, %v, want %v, %v", got, cost, tt.want, tt.cost)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package routers
+
+import (
+	"sort"
+	"time"
+)
+
+// StateRequest ... Ask a router for a snapshot of its learned state, answered on Reply
+type StateRequest struct {
+	Reply chan<- RouterState
+}
+
+// RouterState ... Snapshot of a router's address assignment and learned view of the network
+type RouterState struct {
+	ID         RouterId
+	Address    IPv4         // Host address with the CIDR prefix of the router's block
+	Network    IPv4         // Network ID of the router's block
+	Table      *DVRTable    // Copy of the learned routing table
+	Neighbours NeighbourMap // Mapping of neighbour RouterId to local channel index
+	// Envelopes forwarded over each outgoing link, by neighbour
+	Utilisation map[RouterId]uint64
+	// Envelopes first sent to a loop free alternate by this router because their primary next hops had failed
+	FastReroutes uint64
+	// Forwarding decisions made by label and by routing table lookup
+	Forwarding ForwardingCounters
+}
+
+// snapshot ... Copy the router's state so it can be handed to another goroutine
+func snapshot(self RouterId, RouterIPAddress IPv4, networkAddress IPv4, RoutingTable *DVRTable, NMap NeighbourMap, st *routerState) RouterState {
+	neighbours := make(NeighbourMap, len(NMap))
+	for id, idx := range NMap {
+		neighbours[id] = idx
+	}
+	utilisation := make(map[RouterId]uint64, len(st.balance.utilisation))
+	for id, count := range st.balance.utilisation {
+		utilisation[id] = count
+	}
+	network := networkAddress
+	network.Prefix = RouterIPAddress.Prefix
+	return RouterState{
+		ID:           self,
+		Address:      RouterIPAddress,
+		Network:      network,
+		Table:        RoutingTable.Snapshot(),
+		Neighbours:   neighbours,
+		Utilisation:  utilisation,
+		FastReroutes: st.fib.saved,
+		Forwarding:   st.labels.counters,
+	}
+}
+
+// QueryState ... Collect a state snapshot from every router, skipping any that do not answer within {timeout}
+func QueryState(in []chan<- interface{}, timeout time.Duration) []RouterState {
+	replies := make(chan RouterState, len(in))
+	for _, router := range in {
+		go func(r chan<- interface{}) {
+			select {
+			case r <- StateRequest{Reply: replies}:
+			case <-time.After(timeout):
+			}
+		}(router)
+	}
+	states := make([]RouterState, 0, len(in))
+	deadline := time.After(timeout)
+	for len(states) < len(in) {
+		select {
+		case s := <-replies:
+			states = append(states, s)
+		case <-deadline:
+			sort.Slice(states, func(i, j int) bool { return states[i].ID < states[j].ID })
+			return states
+		}
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].ID < states[j].ID })
+	return states
+}
+
+// ShortestPathTree ... Parent of each reachable router on the router's least cost paths, as learned in its table
+func (s RouterState) ShortestPathTree() map[RouterId]RouterId {
+	_, parents := shortestPaths(s.Table, s.ID)
+	return parents
+}
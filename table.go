@@ -1,44 +1,271 @@
 package routers
 
-// DVRTable ...
-type DVRTable map[RouterId]map[RouterId]interface{}
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+)
 
-// Row ...
-type Row map[RouterId]interface{}
+// Entry ... Learned state of a single directed link
+type Entry struct {
+	Cost        float64   `json:"cost"`
+	LearnedFrom RouterId  `json:"learned_from"` // Origin of the topology update that installed the entry
+	Updated     time.Time `json:"updated"`
+	Sequence    uint64    `json:"sequence"` // Origin's sequence number of that topology update
+}
 
-// DVRTable.put ... Place a value at location (i,j)
-func (m DVRTable) put(i RouterId, j RouterId, value interface{}) {
-	inner, ok := m[i]
-	if !ok {
-		inner = make(Row, j+1)
+// DVRTable ... Learned link state of the network, indexed by (from, to), alongside the multicast groups each
+// router has joined and the IPv4 prefixes it advertises. Safe for concurrent readers and writers
+type DVRTable struct {
+	lock     sync.RWMutex
+	links    map[RouterId]map[RouterId]Entry
+	groups   map[RouterId][]GroupId
+	prefixes map[RouterId][]IPv4
+	trie     prefixTrie // The advertised prefixes, for longest prefix matching
+	version  uint64     // Incremented whenever a link is added, removed or changes cost
+	changed  time.Time  // When version was last incremented
+}
+
+// NewDVRTable ... Create an empty table
+func NewDVRTable() *DVRTable {
+	return &DVRTable{
+		links:    make(map[RouterId]map[RouterId]Entry),
+		groups:   make(map[RouterId][]GroupId),
+		prefixes: make(map[RouterId][]IPv4),
 	}
-	inner[j] = value
-	m[i] = inner
 }
 
-// DVRTable.putRow ... Place a row of values at location (i,)
-func (m DVRTable) putRow(i RouterId, row Row) bool {
-	_, ok := m[i]
+// Put ... Install the entry for the link (from, to), replacing any existing entry
+func (m *DVRTable) Put(from RouterId, to RouterId, entry Entry) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	row, ok := m.links[from]
 	if !ok {
-		m[i] = row
+		row = make(map[RouterId]Entry)
+		m.links[from] = row
 	}
-	return ok
+	if existing, ok := row[to]; !ok || existing.Cost != entry.Cost {
+		m.bump()
+	}
+	row[to] = entry
 }
 
-// DVRTable.get ... Retrieve a value at location (i,j)
-func (m DVRTable) get(i RouterId, j RouterId) interface{} {
-	inner, ok := m[i]
-	if !ok {
-		return nil
+// Get ... Retrieve the entry for the link (from, to)
+func (m *DVRTable) Get(from RouterId, to RouterId) (Entry, bool) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	entry, ok := m.links[from][to]
+	return entry, ok
+}
+
+// Cost ... Retrieve the cost of the link (from, to)
+func (m *DVRTable) Cost(from RouterId, to RouterId) (float64, bool) {
+	entry, ok := m.Get(from, to)
+	return entry.Cost, ok
+}
+
+// Neighbours ... Routers with a link from {id}, in ascending order
+func (m *DVRTable) Neighbours(id RouterId) []RouterId {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	neighbours := make([]RouterId, 0, len(m.links[id]))
+	for n := range m.links[id] {
+		neighbours = append(neighbours, n)
 	}
-	return inner[j]
+	sort.Slice(neighbours, func(i, j int) bool { return neighbours[i] < neighbours[j] })
+	return neighbours
 }
 
-// DVRTable.getRow ... Retrieve a row at location (i,)
-func (m DVRTable) getRow(i RouterId) Row {
-	inner, ok := m[i]
-	if !ok {
-		return nil
+// Links ... Cost of each link from {id}
+func (m *DVRTable) Links(id RouterId) map[RouterId]float64 {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	links := make(map[RouterId]float64, len(m.links[id]))
+	for n, entry := range m.links[id] {
+		links[n] = entry.Cost
 	}
-	return inner
+	return links
+}
+
+// Routers ... Every router with at least one link from it, in ascending order
+func (m *DVRTable) Routers() []RouterId {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	ids := make([]RouterId, 0, len(m.links))
+	for id := range m.links {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// RemoveLink ... Remove the link (from, to), returning whether it was present
+func (m *DVRTable) RemoveLink(from RouterId, to RouterId) bool {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if _, ok := m.links[from][to]; !ok {
+		return false
+	}
+	delete(m.links[from], to)
+	if len(m.links[from]) == 0 {
+		delete(m.links, from)
+	}
+	m.bump()
+	return true
+}
+
+// RemoveRouter ... Remove every link to or from {id}
+func (m *DVRTable) RemoveRouter(id RouterId) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if _, ok := m.links[id]; ok {
+		delete(m.links, id)
+		m.bump()
+	}
+	delete(m.groups, id)
+	m.setPrefixes(id, nil)
+	for from, row := range m.links {
+		if _, ok := row[id]; ok {
+			delete(row, id)
+			m.bump()
+		}
+		if len(row) == 0 {
+			delete(m.links, from)
+		}
+	}
+}
+
+// SetGroups ... Replace the multicast groups {id} has joined
+func (m *DVRTable) SetGroups(id RouterId, groups []GroupId) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if len(groups) == 0 {
+		delete(m.groups, id)
+		return
+	}
+	sorted := append([]GroupId(nil), groups...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	m.groups[id] = sorted
+}
+
+// Groups ... Multicast groups {id} has joined, in ascending order
+func (m *DVRTable) Groups(id RouterId) []GroupId {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	return append([]GroupId(nil), m.groups[id]...)
+}
+
+// Members ... Routers that have joined {group}, in ascending order
+func (m *DVRTable) Members(group GroupId) []RouterId {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	members := make([]RouterId, 0)
+	for id, groups := range m.groups {
+		for _, g := range groups {
+			if g == group {
+				members = append(members, id)
+				break
+			}
+		}
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i] < members[j] })
+	return members
+}
+
+// SetPrefixes ... Replace the IPv4 prefixes {id} advertises
+func (m *DVRTable) SetPrefixes(id RouterId, prefixes []IPv4) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.setPrefixes(id, prefixes)
+}
+
+func (m *DVRTable) setPrefixes(id RouterId, prefixes []IPv4) {
+	for _, p := range m.prefixes[id] {
+		m.trie.remove(p.toUint32(), p.Prefix, id)
+	}
+	if len(prefixes) == 0 {
+		delete(m.prefixes, id)
+		return
+	}
+	m.prefixes[id] = append([]IPv4(nil), prefixes...)
+	for _, p := range prefixes {
+		m.trie.insert(p.toUint32(), p.Prefix, id)
+	}
+}
+
+// Prefixes ... IPv4 prefixes {id} advertises
+func (m *DVRTable) Prefixes(id RouterId) []IPv4 {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	return append([]IPv4(nil), m.prefixes[id]...)
+}
+
+// AdvertisedPrefixes ... IPv4 prefixes advertised by every router
+func (m *DVRTable) AdvertisedPrefixes() map[RouterId][]IPv4 {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	copied := make(map[RouterId][]IPv4, len(m.prefixes))
+	for id, prefixes := range m.prefixes {
+		copied[id] = append([]IPv4(nil), prefixes...)
+	}
+	return copied
+}
+
+// LookupPrefix ... The longest advertised prefix holding {addr} and the routers advertising it, in ascending order
+func (m *DVRTable) LookupPrefix(addr IPv4) (IPv4, []RouterId, bool) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	key, length, owners, ok := m.trie.lookup(addr.toUint32())
+	sort.Slice(owners, func(i, j int) bool { return owners[i] < owners[j] })
+	return ipv4FromUint32(key, length), owners, ok
+}
+
+// Version ... Counter identifying the table's current links and costs, changing whenever either does
+func (m *DVRTable) Version() uint64 {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	return m.version
+}
+
+// Changed ... The table's version alongside when it last changed, zero if it never has
+func (m *DVRTable) Changed() (uint64, time.Time) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	return m.version, m.changed
+}
+
+// bump ... Record a change to the links or costs, the lock already held for writing
+func (m *DVRTable) bump() {
+	m.version++
+	m.changed = time.Now()
+}
+
+// Snapshot ... Independent copy of the table
+func (m *DVRTable) Snapshot() *DVRTable {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	copied := NewDVRTable()
+	copied.version, copied.changed = m.version, m.changed
+	for from, row := range m.links {
+		r := make(map[RouterId]Entry, len(row))
+		for to, entry := range row {
+			r[to] = entry
+		}
+		copied.links[from] = r
+	}
+	for id, groups := range m.groups {
+		copied.groups[id] = append([]GroupId(nil), groups...)
+	}
+	for id, prefixes := range m.prefixes {
+		copied.setPrefixes(id, prefixes)
+	}
+	return copied
+}
+
+// MarshalJSON ... Encode the table as {from: {to: entry}}
+func (m *DVRTable) MarshalJSON() ([]byte, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	return json.Marshal(m.links)
 }
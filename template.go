@@ -0,0 +1,201 @@
+package routers
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ProblemKind ... Category of defect found when validating a Template
+type ProblemKind int
+
+const (
+	// OutOfRange ... A neighbour RouterId with no router in the template
+	OutOfRange ProblemKind = iota
+	// SelfLoop ... A router listing itself as a neighbour
+	SelfLoop
+	// DuplicateNeighbour ... A router listing the same neighbour more than once
+	DuplicateNeighbour
+	// OneSidedLink ... A router listing a neighbour that does not list it back
+	OneSidedLink
+	// IsolatedRouter ... A router with no neighbours in a network of more than one router
+	IsolatedRouter
+	// CostWithoutLink ... A cost given for a link the template doesn't have
+	CostWithoutLink
+	// NegativeCost ... A link with a cost below zero
+	NegativeCost
+)
+
+func (k ProblemKind) String() string {
+	switch k {
+	case OutOfRange:
+		return "out of range neighbour"
+	case SelfLoop:
+		return "self loop"
+	case DuplicateNeighbour:
+		return "duplicate neighbour"
+	case OneSidedLink:
+		return "one sided link"
+	case IsolatedRouter:
+		return "isolated router"
+	case CostWithoutLink:
+		return "cost without a link"
+	case NegativeCost:
+		return "negative cost"
+	default:
+		return fmt.Sprintf("ProblemKind(%d)", int(k))
+	}
+}
+
+// Problem ... A single defect in a Template, located by router and position in its neighbour list
+type Problem struct {
+	Kind      ProblemKind
+	Router    RouterId
+	Neighbour RouterId // Offending neighbour, unused for IsolatedRouter
+	Index     int      // Position of the neighbour in the router's list, -1 for IsolatedRouter and CostWithoutLink
+	Cost      float64  // Offending cost, for CostWithoutLink and NegativeCost
+}
+
+func (p Problem) Error() string {
+	switch p.Kind {
+	case IsolatedRouter:
+		return fmt.Sprintf("router %v: %v", p.Router, p.Kind)
+	case CostWithoutLink, NegativeCost:
+		return fmt.Sprintf("router %v: %v %v to %v", p.Router, p.Kind, p.Cost, p.Neighbour)
+	}
+	return fmt.Sprintf("router %v: %v %v (neighbour index %v)", p.Router, p.Kind, p.Neighbour, p.Index)
+}
+
+// Problems ... Every defect found in a Template, usable as an error
+type Problems []Problem
+
+func (p Problems) Error() string {
+	lines := make([]string, len(p))
+	for i, problem := range p {
+		lines[i] = problem.Error()
+	}
+	return fmt.Sprintf("invalid template, %v problems:\n  %s", len(p), strings.Join(lines, "\n  "))
+}
+
+// Validate ... Check every router's neighbour list, returning nil when the template is usable as is
+func (t Template) Validate() Problems {
+	problems := make(Problems, 0)
+	for i, neighbours := range t {
+		id := RouterId(i)
+		seen := make(map[RouterId]bool, len(neighbours))
+		for j, n := range neighbours {
+			switch {
+			case int(n) >= len(t):
+				problems = append(problems, Problem{Kind: OutOfRange, Router: id, Neighbour: n, Index: j})
+			case n == id:
+				problems = append(problems, Problem{Kind: SelfLoop, Router: id, Neighbour: n, Index: j})
+			case seen[n]:
+				problems = append(problems, Problem{Kind: DuplicateNeighbour, Router: id, Neighbour: n, Index: j})
+			case !hasLink(t[n], id):
+				problems = append(problems, Problem{Kind: OneSidedLink, Router: id, Neighbour: n, Index: j})
+			}
+			seen[n] = true
+		}
+		if len(t) > 1 && len(neighbours) == 0 {
+			problems = append(problems, Problem{Kind: IsolatedRouter, Router: id, Index: -1})
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	return problems
+}
+
+// Symmetrise ... Copy of the template with out of range neighbours, self loops and duplicates dropped,
+// and the reverse of every one sided link added. Isolated routers remain isolated
+func (t Template) Symmetrise() Template {
+	s := make(Template, len(t))
+	for i := range s {
+		s[i] = make([]RouterId, 0, len(t[i]))
+	}
+	for i, neighbours := range t {
+		id := RouterId(i)
+		for _, n := range neighbours {
+			if int(n) >= len(t) || n == id {
+				continue
+			}
+			if !hasLink(s[id], n) {
+				s[id] = append(s[id], n)
+			}
+			if !hasLink(s[n], id) {
+				s[n] = append(s[n], id)
+			}
+		}
+	}
+	return s
+}
+
+// WeightedTemplate ... A template along with the cost of each of its links, links without an entry costing 1
+type WeightedTemplate struct {
+	Template Template
+	Costs    Costs
+}
+
+// neighbourIndex ... Position of {to} in the neighbour list of {from}, -1 if the template has no such link
+func (t Template) neighbourIndex(from RouterId, to RouterId) int {
+	if int(from) >= len(t) {
+		return -1
+	}
+	for i, n := range t[from] {
+		if n == to {
+			return i
+		}
+	}
+	return -1
+}
+
+// Validate ... Check the template, then that every cost is of one of its links and isn't negative, returning nil
+// when both are usable as they are
+func (w WeightedTemplate) Validate() Problems {
+	problems := w.Template.Validate()
+	links := make([]Link, 0, len(w.Costs))
+	for l := range w.Costs {
+		links = append(links, l)
+	}
+	sort.Slice(links, func(i, j int) bool {
+		if links[i].From != links[j].From {
+			return links[i].From < links[j].From
+		}
+		return links[i].To < links[j].To
+	})
+	for _, l := range links {
+		c := w.Costs[l]
+		index := w.Template.neighbourIndex(l.From, l.To)
+		switch {
+		case index < 0:
+			problems = append(problems, Problem{Kind: CostWithoutLink, Router: l.From, Neighbour: l.To, Index: -1, Cost: c})
+		case c < 0:
+			problems = append(problems, Problem{Kind: NegativeCost, Router: l.From, Neighbour: l.To, Index: index, Cost: c})
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	return problems
+}
+
+// Symmetrise ... Copy of the weighted template with the template symmetrised and the costs of dropped links
+// dropped. A link added as the reverse of a one sided link takes the one sided link's cost
+func (w WeightedTemplate) Symmetrise() WeightedTemplate {
+	s := WeightedTemplate{Template: w.Template.Symmetrise()}
+	if w.Costs == nil {
+		return s
+	}
+	s.Costs = make(Costs, len(w.Costs))
+	for l, c := range w.Costs {
+		if s.Template.neighbourIndex(l.From, l.To) < 0 {
+			continue
+		}
+		s.Costs[l] = c
+		reverse := Link{l.To, l.From}
+		if _, costed := w.Costs[reverse]; !costed && w.Template.neighbourIndex(l.To, l.From) < 0 {
+			s.Costs[reverse] = c
+		}
+	}
+	return s
+}
@@ -0,0 +1,149 @@
+package routers
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTemplateValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		template Template
+		want     Problems
+	}{
+		{"empty", Template{}, nil},
+		{"single router", Template{{}}, nil},
+		{"ring", Template{{1, 2}, {0, 2}, {1, 0}}, nil},
+		{
+			"out of range",
+			Template{{1, 5}, {0}},
+			Problems{{Kind: OutOfRange, Router: 0, Neighbour: 5, Index: 1}},
+		},
+		{
+			"self loop",
+			Template{{0, 1}, {0}},
+			Problems{{Kind: SelfLoop, Router: 0, Neighbour: 0, Index: 0}},
+		},
+		{
+			"duplicate neighbour",
+			Template{{1, 1}, {0}},
+			Problems{{Kind: DuplicateNeighbour, Router: 0, Neighbour: 1, Index: 1}},
+		},
+		{
+			"one sided link",
+			Template{{1, 2}, {0}, {1}},
+			Problems{
+				{Kind: OneSidedLink, Router: 0, Neighbour: 2, Index: 1},
+				{Kind: OneSidedLink, Router: 2, Neighbour: 1, Index: 0},
+			},
+		},
+		{
+			"isolated router",
+			Template{{1}, {0}, {}},
+			Problems{{Kind: IsolatedRouter, Router: 2, Index: -1}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.template.Validate(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Validate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTemplateSymmetrise(t *testing.T) {
+	tests := []struct {
+		name     string
+		template Template
+		want     Template
+	}{
+		// Neighbours are listed in the order their links are first seen
+		{"valid", Template{{1, 2}, {0, 2}, {1, 0}}, Template{{1, 2}, {0, 2}, {0, 1}}},
+		{"one sided link reversed", Template{{1}, {}}, Template{{1}, {0}}},
+		{"out of range dropped", Template{{1, 4}, {0}}, Template{{1}, {0}}},
+		{"self loop dropped", Template{{0, 1}, {1, 0}}, Template{{1}, {0}}},
+		{"duplicate dropped", Template{{1, 1}, {0, 0}}, Template{{1}, {0}}},
+		{"isolated kept", Template{{1}, {0}, {}}, Template{{1}, {0}, {}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.template.Symmetrise(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Symmetrise() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWeightedTemplateValidate(t *testing.T) {
+	line := Template{{1}, {0, 2}, {1}}
+	tests := []struct {
+		name     string
+		weighted WeightedTemplate
+		want     Problems
+	}{
+		{"no costs", WeightedTemplate{Template: line}, nil},
+		{"costed links", WeightedTemplate{line, Costs{{0, 1}: 2, {1, 0}: 0.5, {2, 1}: 0}}, nil},
+		{
+			"cost without link",
+			WeightedTemplate{line, Costs{{0, 2}: 3, {5, 0}: 1}},
+			Problems{
+				{Kind: CostWithoutLink, Router: 0, Neighbour: 2, Index: -1, Cost: 3},
+				{Kind: CostWithoutLink, Router: 5, Neighbour: 0, Index: -1, Cost: 1},
+			},
+		},
+		{
+			"negative cost",
+			WeightedTemplate{line, Costs{{1, 2}: -1}},
+			Problems{{Kind: NegativeCost, Router: 1, Neighbour: 2, Index: 1, Cost: -1}},
+		},
+		{
+			"template and cost problems",
+			WeightedTemplate{Template{{1}, {}}, Costs{{1, 0}: 1}},
+			Problems{
+				{Kind: OneSidedLink, Router: 0, Neighbour: 1, Index: 0},
+				{Kind: IsolatedRouter, Router: 1, Index: -1},
+				{Kind: CostWithoutLink, Router: 1, Neighbour: 0, Index: -1, Cost: 1},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.weighted.Validate(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Validate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWeightedTemplateSymmetrise(t *testing.T) {
+	tests := []struct {
+		name     string
+		weighted WeightedTemplate
+		want     WeightedTemplate
+	}{
+		{"no costs", WeightedTemplate{Template: Template{{1}, {}}}, WeightedTemplate{Template: Template{{1}, {0}}}},
+		{
+			"reverse takes cost",
+			WeightedTemplate{Template{{1}, {}}, Costs{{0, 1}: 4}},
+			WeightedTemplate{Template{{1}, {0}}, Costs{{0, 1}: 4, {1, 0}: 4}},
+		},
+		{
+			"asymmetric costs kept",
+			WeightedTemplate{Template{{1}, {0}}, Costs{{0, 1}: 4}},
+			WeightedTemplate{Template{{1}, {0}}, Costs{{0, 1}: 4}},
+		},
+		{
+			"costs of dropped links dropped",
+			WeightedTemplate{Template{{0, 1, 7}, {0}}, Costs{{0, 0}: 2, {0, 7}: 3, {0, 1}: 1}},
+			WeightedTemplate{Template{{1}, {0}}, Costs{{0, 1}: 1}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.weighted.Symmetrise(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Symmetrise() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
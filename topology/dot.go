@@ -0,0 +1,268 @@
+package topology
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// dotParser ... Recursive descent parser for the subset of the Graphviz DOT language describing nodes and edges.
+// Node "label" attributes name routers and edge "weight" or "cost" attributes weight links. Subgraphs are
+// flattened, and ports and default attribute statements are ignored
+type dotParser struct {
+	tokens   []string
+	pos      int
+	directed bool
+	builder  *networkBuilder
+}
+
+// ReadDOT ... Read a Graphviz DOT graph or digraph
+func ReadDOT(r io.Reader) (*Network, error) {
+	src, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	tokens, err := tokeniseDOT(string(src))
+	if err != nil {
+		return nil, err
+	}
+	p := &dotParser{tokens: tokens, builder: newNetworkBuilder()}
+	if err := p.graph(); err != nil {
+		return nil, err
+	}
+	return p.builder.build()
+}
+
+// tokeniseDOT ... Split DOT source into identifiers, quoted strings and punctuation, dropping comments
+func tokeniseDOT(src string) ([]string, error) {
+	tokens := make([]string, 0)
+	runes := []rune(src)
+	for i := 0; i < len(runes); {
+		c := runes[i]
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '#' && (i == 0 || runes[i-1] == '\n'), c == '/' && i+1 < len(runes) && runes[i+1] == '/':
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+		case c == '/' && i+1 < len(runes) && runes[i+1] == '*':
+			i += 2
+			for i+1 < len(runes) && !(runes[i] == '*' && runes[i+1] == '/') {
+				i++
+			}
+			if i+1 >= len(runes) {
+				return nil, fmt.Errorf("unterminated comment")
+			}
+			i += 2
+		case c == '"':
+			var sb strings.Builder
+			sb.WriteRune('"')
+			i++
+			for ; i < len(runes) && runes[i] != '"'; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+				}
+				sb.WriteRune(runes[i])
+			}
+			if i >= len(runes) {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			i++
+			tokens = append(tokens, sb.String())
+		case c == '-' && i+1 < len(runes) && (runes[i+1] == '-' || runes[i+1] == '>'):
+			tokens = append(tokens, string(runes[i:i+2]))
+			i += 2
+		case strings.ContainsRune("{}[];,=:", c):
+			tokens = append(tokens, string(c))
+			i++
+		case c == '_' || c == '.' || c == '-' || unicode.IsLetter(c) || unicode.IsDigit(c):
+			start := i
+			for i < len(runes) && (runes[i] == '_' || runes[i] == '.' || unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) ||
+				(runes[i] == '-' && i == start)) {
+				i++
+			}
+			tokens = append(tokens, string(runes[start:i]))
+		default:
+			return nil, fmt.Errorf("unexpected character %q", c)
+		}
+	}
+	return tokens, nil
+}
+
+func (p *dotParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *dotParser) next() string {
+	t := p.peek()
+	p.pos++
+	return t
+}
+
+func (p *dotParser) expect(token string) error {
+	if t := p.next(); t != token {
+		return fmt.Errorf("expected %q, found %q", token, t)
+	}
+	return nil
+}
+
+// id ... Consume an identifier, unquoting quoted strings
+func (p *dotParser) id() (string, error) {
+	t := p.next()
+	if t == "" || strings.ContainsAny(t[:1], "{}[];,=:") || t == "--" || t == "->" {
+		return "", fmt.Errorf("expected identifier, found %q", t)
+	}
+	return strings.TrimPrefix(t, "\""), nil
+}
+
+// graph ... [strict] (graph | digraph) [ID] '{' stmt_list '}'
+func (p *dotParser) graph() error {
+	if strings.ToLower(p.peek()) == "strict" {
+		p.next()
+	}
+	switch strings.ToLower(p.next()) {
+	case "graph":
+	case "digraph":
+		p.directed = true
+	default:
+		return fmt.Errorf("expected graph or digraph")
+	}
+	if p.peek() != "{" {
+		if _, err := p.id(); err != nil {
+			return err
+		}
+	}
+	return p.block()
+}
+
+// block ... '{' stmt_list '}'
+func (p *dotParser) block() error {
+	if err := p.expect("{"); err != nil {
+		return err
+	}
+	for p.peek() != "}" {
+		if p.peek() == "" {
+			return fmt.Errorf("unexpected end of graph")
+		}
+		if err := p.statement(); err != nil {
+			return err
+		}
+		if p.peek() == ";" || p.peek() == "," {
+			p.next()
+		}
+	}
+	p.next()
+	return nil
+}
+
+// statement ... Attribute, subgraph, node or edge statement
+func (p *dotParser) statement() error {
+	switch strings.ToLower(p.peek()) {
+	case "graph", "node", "edge":
+		p.next()
+		_, err := p.attributes()
+		return err
+	case "subgraph":
+		p.next()
+		if p.peek() != "{" {
+			if _, err := p.id(); err != nil {
+				return err
+			}
+		}
+		return p.block()
+	case "{":
+		return p.block()
+	}
+	first, err := p.nodeID()
+	if err != nil {
+		return err
+	}
+	if p.peek() == "=" {
+		// Graph attribute assignment
+		p.next()
+		_, err := p.id()
+		return err
+	}
+	path := []string{first}
+	for p.peek() == "--" || p.peek() == "->" {
+		p.next()
+		n, err := p.nodeID()
+		if err != nil {
+			return err
+		}
+		path = append(path, n)
+	}
+	attrs, err := p.attributes()
+	if err != nil {
+		return err
+	}
+	if len(path) == 1 {
+		p.builder.node(first)
+		if label, ok := attrs["label"]; ok {
+			p.builder.label(first, label)
+		}
+		return nil
+	}
+	for i := 0; i < len(path)-1; i++ {
+		p.builder.link(path[i], path[i+1], p.directed)
+		for _, key := range []string{"weight", "cost"} {
+			if v, ok := attrs[key]; ok {
+				w, err := strconv.ParseFloat(v, 64)
+				if err != nil {
+					return fmt.Errorf("edge %v -> %v: invalid %s %q", path[i], path[i+1], key, v)
+				}
+				p.builder.weight(w, p.directed)
+			}
+		}
+	}
+	return nil
+}
+
+// nodeID ... ID [':' port [':' compass]], discarding ports
+func (p *dotParser) nodeID() (string, error) {
+	id, err := p.id()
+	if err != nil {
+		return "", err
+	}
+	for p.peek() == ":" {
+		p.next()
+		if _, err := p.id(); err != nil {
+			return "", err
+		}
+	}
+	return id, nil
+}
+
+// attributes ... Zero or more '[' (ID '=' ID [';' | ','])* ']' lists, merged with lower case keys
+func (p *dotParser) attributes() (map[string]string, error) {
+	attrs := make(map[string]string)
+	for p.peek() == "[" {
+		p.next()
+		for p.peek() != "]" {
+			key, err := p.id()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect("="); err != nil {
+				return nil, err
+			}
+			value, err := p.id()
+			if err != nil {
+				return nil, err
+			}
+			attrs[strings.ToLower(key)] = value
+			if p.peek() == ";" || p.peek() == "," {
+				p.next()
+			}
+		}
+		p.next()
+	}
+	return attrs, nil
+}
@@ -0,0 +1,83 @@
+package topology
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+
+	"routers"
+)
+
+// WriteJSON ... Write the network as a JSON adjacency document readable by ReadJSON
+func WriteJSON(w io.Writer, n *Network) error {
+	doc := jsonDocument{Routers: make([]jsonRouter, len(n.Template))}
+	for i, neighbours := range n.Template {
+		r := jsonRouter{Neighbours: neighbours}
+		if n.Names != nil {
+			r.Name = n.Names[i]
+		}
+		if n.Weights != nil {
+			r.Weights = make([]float64, len(neighbours))
+			for j, neighbour := range neighbours {
+				r.Weights[j] = n.weight(routers.RouterId(i), neighbour)
+			}
+		}
+		doc.Routers[i] = r
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(doc)
+}
+
+// WriteDOT ... Write the network as a Graphviz DOT graph readable by ReadDOT. Networks whose links and weights
+// are all symmetric are written as an undirected graph, anything else as a digraph
+func WriteDOT(w io.Writer, n *Network) error {
+	symmetric := true
+	for i, neighbours := range n.Template {
+		for _, to := range neighbours {
+			from := routers.RouterId(i)
+			if !linked(n.Template, to, from) || n.weight(from, to) != n.weight(to, from) {
+				symmetric = false
+			}
+		}
+	}
+	kind, op := "digraph", "->"
+	if symmetric {
+		kind, op = "graph", "--"
+	}
+
+	b := bufio.NewWriter(w)
+	fmt.Fprintf(b, "%s network {\n", kind)
+	for i := range n.Template {
+		if n.Names != nil {
+			fmt.Fprintf(b, "  %v [label=%v];\n", i, strconv.Quote(n.Names[i]))
+		} else {
+			fmt.Fprintf(b, "  %v;\n", i)
+		}
+	}
+	for i, neighbours := range n.Template {
+		from := routers.RouterId(i)
+		for _, to := range neighbours {
+			if symmetric && to < from {
+				continue
+			}
+			if _, ok := n.Weights[Link{From: from, To: to}]; ok {
+				fmt.Fprintf(b, "  %v %s %v [weight=%v];\n", from, op, to, n.weight(from, to))
+			} else {
+				fmt.Fprintf(b, "  %v %s %v;\n", from, op, to)
+			}
+		}
+	}
+	fmt.Fprintln(b, "}")
+	return b.Flush()
+}
+
+// weight ... Cost of the link from {from} to {to}, defaulting to 1 when undeclared
+func (n *Network) weight(from routers.RouterId, to routers.RouterId) float64 {
+	if w, ok := n.Weights[Link{From: from, To: to}]; ok {
+		return w
+	}
+	return 1
+}
@@ -0,0 +1,346 @@
+package topology
+
+import (
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"routers"
+)
+
+// Link ... A directed link between two routers
+type Link = routers.Link
+
+// Weights ... Cost of traversing each link, as in routers.WeightedTemplate
+type Weights = routers.Costs
+
+// Network ... A template read from a file, with the router names and link weights it declared
+type Network struct {
+	Template routers.Template
+	Names    []string // Indexed by RouterId, nil when the file numbered its routers
+	Weights  Weights  // Nil when the file declared no weights
+}
+
+// Weighted ... The template with its link weights as costs
+func (n *Network) Weighted() routers.WeightedTemplate {
+	return routers.WeightedTemplate{Template: n.Template, Costs: n.Weights}
+}
+
+// LoadFile ... Read a network from a file, choosing the format by extension
+// (.txt/.edges edge list, .json, .graphml/.xml, .dot/.gv)
+func LoadFile(path string) (*Network, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var n *Network
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".txt", ".edges", ".el", "":
+		n, err = ReadEdgeList(f)
+	case ".json":
+		n, err = ReadJSON(f)
+	case ".graphml", ".xml":
+		n, err = ReadGraphML(f)
+	case ".dot", ".gv":
+		n, err = ReadDOT(f)
+	default:
+		return nil, fmt.Errorf("%s: unsupported topology format %q", path, ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return n, nil
+}
+
+// networkBuilder ... Accumulates named routers and links in file order before numbering them
+type networkBuilder struct {
+	ids      []string
+	index    map[string]int
+	labels   map[string]string
+	links    [][2]string
+	weights  map[int]float64
+	weighted bool
+}
+
+func newNetworkBuilder() *networkBuilder {
+	return &networkBuilder{
+		index:   make(map[string]int),
+		labels:  make(map[string]string),
+		weights: make(map[int]float64),
+	}
+}
+
+// node ... Declare a router by its file identifier
+func (b *networkBuilder) node(id string) {
+	if _, ok := b.index[id]; !ok {
+		b.index[id] = len(b.ids)
+		b.ids = append(b.ids, id)
+	}
+}
+
+// label ... Give a router a display name other than its identifier
+func (b *networkBuilder) label(id string, name string) {
+	b.node(id)
+	b.labels[id] = name
+}
+
+// link ... Declare a link, in both directions unless {directed}
+func (b *networkBuilder) link(from string, to string, directed bool) {
+	b.node(from)
+	b.node(to)
+	b.links = append(b.links, [2]string{from, to})
+	if !directed {
+		b.links = append(b.links, [2]string{to, from})
+	}
+}
+
+// weight ... Set the weight of the most recently declared link (in both directions unless {directed})
+func (b *networkBuilder) weight(w float64, directed bool) {
+	b.weighted = true
+	b.weights[len(b.links)-1] = w
+	if !directed {
+		b.weights[len(b.links)-2] = w
+	}
+}
+
+// build ... Number the routers and produce the network. Identifiers that are exactly the integers 0 to n-1 are used
+// as RouterIds directly, otherwise (1-based or sparse numbering included) routers are numbered in order of first
+// appearance and named by identifier
+func (b *networkBuilder) build() (*Network, error) {
+	numeric := len(b.labels) == 0
+	ids := make(map[string]routers.RouterId, len(b.ids))
+	seen := make(map[uint64]bool, len(b.ids))
+	size := len(b.ids)
+	for _, id := range b.ids {
+		v, err := strconv.ParseUint(id, 10, 32)
+		if err != nil || v >= uint64(len(b.ids)) || seen[v] {
+			numeric = false
+			break
+		}
+		seen[v] = true
+		ids[id] = routers.RouterId(v)
+	}
+	n := &Network{}
+	if !numeric {
+		n.Names = make([]string, size)
+		for i, id := range b.ids {
+			ids[id] = routers.RouterId(i)
+			n.Names[i] = id
+			if name, ok := b.labels[id]; ok {
+				n.Names[i] = name
+			}
+		}
+	}
+	if size == 0 {
+		return nil, ErrNoRouters
+	}
+	n.Template = newTemplate(uint(size))
+	if b.weighted {
+		n.Weights = make(Weights)
+	}
+	for i, l := range b.links {
+		from, to := ids[l[0]], ids[l[1]]
+		if !linked(n.Template, from, to) {
+			n.Template[from] = append(n.Template[from], to)
+		}
+		if w, ok := b.weights[i]; ok {
+			n.Weights[Link{From: from, To: to}] = w
+		}
+	}
+	return n, nil
+}
+
+// ReadEdgeList ... Read lines of "from to [weight]" declaring undirected links. A line holding a single
+// router declares it without links, and anything after # or % is a comment
+func ReadEdgeList(r io.Reader) (*Network, error) {
+	b := newNetworkBuilder()
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if i := strings.IndexAny(text, "#%"); i >= 0 {
+			text = text[:i]
+		}
+		fields := strings.Fields(text)
+		switch len(fields) {
+		case 0:
+		case 1:
+			b.node(fields[0])
+		case 2, 3:
+			b.link(fields[0], fields[1], false)
+			if len(fields) == 3 {
+				w, err := strconv.ParseFloat(fields[2], 64)
+				if err != nil {
+					return nil, fmt.Errorf("line %v: invalid weight %q", line, fields[2])
+				}
+				b.weight(w, false)
+			}
+		default:
+			return nil, fmt.Errorf("line %v: expected \"from to [weight]\", found %v fields", line, len(fields))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return b.build()
+}
+
+// jsonRouter ... A router of a JSON adjacency document, with optional per neighbour weights
+type jsonRouter struct {
+	Name       string             `json:"name,omitempty"`
+	Neighbours []routers.RouterId `json:"neighbours"`
+	Weights    []float64          `json:"weights,omitempty"`
+}
+
+// jsonLink ... An undirected link of a JSON adjacency document
+type jsonLink struct {
+	Source routers.RouterId `json:"source"`
+	Target routers.RouterId `json:"target"`
+	Weight *float64         `json:"weight,omitempty"`
+}
+
+// jsonDocument ... JSON adjacency document. Routers are numbered by their position in "routers", which list their
+// (directed) neighbours; undirected "links" may be given in addition or instead
+type jsonDocument struct {
+	Routers []jsonRouter `json:"routers"`
+	Links   []jsonLink   `json:"links,omitempty"`
+}
+
+// ReadJSON ... Read a JSON adjacency document
+func ReadJSON(r io.Reader) (*Network, error) {
+	var doc jsonDocument
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Routers) == 0 {
+		return nil, ErrNoRouters
+	}
+	n := &Network{Template: newTemplate(uint(len(doc.Routers)))}
+	named := false
+	for _, router := range doc.Routers {
+		named = named || router.Name != ""
+	}
+	if named {
+		n.Names = make([]string, len(doc.Routers))
+	}
+	add := func(from routers.RouterId, to routers.RouterId, w *float64) error {
+		if int(from) >= len(n.Template) || int(to) >= len(n.Template) {
+			return fmt.Errorf("link [%v] -> [%v] references an undeclared router", from, to)
+		}
+		if !linked(n.Template, from, to) {
+			n.Template[from] = append(n.Template[from], to)
+		}
+		if w != nil {
+			if n.Weights == nil {
+				n.Weights = make(Weights)
+			}
+			n.Weights[Link{From: from, To: to}] = *w
+		}
+		return nil
+	}
+	for i, router := range doc.Routers {
+		if named {
+			n.Names[i] = router.Name
+		}
+		if router.Weights != nil && len(router.Weights) != len(router.Neighbours) {
+			return nil, fmt.Errorf("router %v lists %v neighbours but %v weights", i, len(router.Neighbours), len(router.Weights))
+		}
+		for j, neighbour := range router.Neighbours {
+			var w *float64
+			if router.Weights != nil {
+				w = &router.Weights[j]
+			}
+			if err := add(routers.RouterId(i), neighbour, w); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, l := range doc.Links {
+		if err := add(l.Source, l.Target, l.Weight); err != nil {
+			return nil, err
+		}
+		if err := add(l.Target, l.Source, l.Weight); err != nil {
+			return nil, err
+		}
+	}
+	return n, nil
+}
+
+// GraphML document structure, limited to the parts describing nodes, edges and their attributes
+type graphML struct {
+	Keys  []graphMLKey `xml:"key"`
+	Graph struct {
+		EdgeDefault string        `xml:"edgedefault,attr"`
+		Nodes       []graphMLNode `xml:"node"`
+		Edges       []graphMLEdge `xml:"edge"`
+	} `xml:"graph"`
+}
+
+type graphMLKey struct {
+	ID   string `xml:"id,attr"`
+	For  string `xml:"for,attr"`
+	Name string `xml:"attr.name,attr"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+type graphMLNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphMLData `xml:"data"`
+}
+
+type graphMLEdge struct {
+	Source   string        `xml:"source,attr"`
+	Target   string        `xml:"target,attr"`
+	Directed string        `xml:"directed,attr"`
+	Data     []graphMLData `xml:"data"`
+}
+
+// ReadGraphML ... Read a GraphML document, taking router names from a "name" or "label" node attribute
+// and link weights from a "weight" or "cost" edge attribute
+func ReadGraphML(r io.Reader) (*Network, error) {
+	var doc graphML
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, err
+	}
+	attrs := make(map[string]string, len(doc.Keys))
+	for _, k := range doc.Keys {
+		attrs[k.ID] = strings.ToLower(k.Name)
+	}
+	b := newNetworkBuilder()
+	for _, node := range doc.Graph.Nodes {
+		b.node(node.ID)
+		for _, d := range node.Data {
+			if name := attrs[d.Key]; name == "name" || name == "label" {
+				b.label(node.ID, strings.TrimSpace(d.Value))
+			}
+		}
+	}
+	for _, edge := range doc.Graph.Edges {
+		directed := doc.Graph.EdgeDefault == "directed"
+		if edge.Directed != "" {
+			directed = edge.Directed == "true"
+		}
+		b.link(edge.Source, edge.Target, directed)
+		for _, d := range edge.Data {
+			if name := attrs[d.Key]; name == "weight" || name == "cost" {
+				w, err := strconv.ParseFloat(strings.TrimSpace(d.Value), 64)
+				if err != nil {
+					return nil, fmt.Errorf("edge %v -> %v: invalid weight %q", edge.Source, edge.Target, d.Value)
+				}
+				b.weight(w, directed)
+			}
+		}
+	}
+	return b.build()
+}
@@ -0,0 +1,147 @@
+package topology
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"routers"
+)
+
+func TestReaders(t *testing.T) {
+	line := routers.Template{{1}, {0, 2}, {1}}
+	tests := []struct {
+		name    string
+		read    func(r io.Reader) (*Network, error)
+		src     string
+		want    *Network
+		wantErr bool
+	}{
+		{"edge list numbered from 0", ReadEdgeList, "0 1\n1 2\n", &Network{Template: line}, false},
+		{"edge list numbered from 1", ReadEdgeList, "1 2\n2 3\n", &Network{Template: line, Names: []string{"1", "2", "3"}}, false},
+		{"edge list sparse ids", ReadEdgeList, "10 20\n20 30\n", &Network{Template: line, Names: []string{"10", "20", "30"}}, false},
+		{"edge list named", ReadEdgeList, "a b\nb c\n", &Network{Template: line, Names: []string{"a", "b", "c"}}, false},
+		{
+			"edge list weights and comments",
+			ReadEdgeList,
+			"# line of three\n0 1 2.5 % weighted\n\n1 2\n",
+			&Network{Template: line, Weights: Weights{{From: 0, To: 1}: 2.5, {From: 1, To: 0}: 2.5}},
+			false,
+		},
+		{"edge list lone router", ReadEdgeList, "0 1\n2\n", &Network{Template: routers.Template{{1}, {0}, {}}}, false},
+		{"edge list duplicate link", ReadEdgeList, "0 1\n1 0\n", &Network{Template: routers.Template{{1}, {0}}}, false},
+		{"edge list empty", ReadEdgeList, "# nothing\n", nil, true},
+		{"edge list bad weight", ReadEdgeList, "0 1 heavy\n", nil, true},
+		{"edge list too many fields", ReadEdgeList, "0 1 2 3\n", nil, true},
+		{
+			"JSON neighbours",
+			ReadJSON,
+			`{"routers": [{"neighbours": [1]}, {"neighbours": [0, 2]}, {"neighbours": [1]}]}`,
+			&Network{Template: line},
+			false,
+		},
+		{
+			"JSON named and weighted",
+			ReadJSON,
+			`{"routers": [{"name": "a", "neighbours": [1], "weights": [3]}, {"name": "b", "neighbours": [0], "weights": [4]}]}`,
+			&Network{
+				Template: routers.Template{{1}, {0}},
+				Names:    []string{"a", "b"},
+				Weights:  Weights{{From: 0, To: 1}: 3, {From: 1, To: 0}: 4},
+			},
+			false,
+		},
+		{
+			"JSON links",
+			ReadJSON,
+			`{"routers": [{}, {}, {}], "links": [{"source": 0, "target": 1}, {"source": 1, "target": 2, "weight": 2}]}`,
+			&Network{Template: line, Weights: Weights{{From: 1, To: 2}: 2, {From: 2, To: 1}: 2}},
+			false,
+		},
+		{"JSON no routers", ReadJSON, `{"routers": []}`, nil, true},
+		{"JSON undeclared router", ReadJSON, `{"routers": [{"neighbours": [3]}]}`, nil, true},
+		{"JSON weight count mismatch", ReadJSON, `{"routers": [{"neighbours": [1], "weights": [1, 2]}, {"neighbours": [0]}]}`, nil, true},
+		{"JSON malformed", ReadJSON, `{"routers": [`, nil, true},
+		{
+			"GraphML undirected",
+			ReadGraphML,
+			`<graphml><graph edgedefault="undirected">
+				<node id="0"/><node id="1"/><node id="2"/>
+				<edge source="0" target="1"/><edge source="1" target="2"/>
+			</graph></graphml>`,
+			&Network{Template: line},
+			false,
+		},
+		{
+			"GraphML labels and weights",
+			ReadGraphML,
+			`<graphml>
+				<key id="d0" for="node" attr.name="label"/><key id="d1" for="edge" attr.name="weight"/>
+				<graph edgedefault="undirected">
+					<node id="n0"><data key="d0">a</data></node><node id="n1"><data key="d0">b</data></node>
+					<edge source="n0" target="n1"><data key="d1"> 1.5 </data></edge>
+				</graph>
+			</graphml>`,
+			&Network{
+				Template: routers.Template{{1}, {0}},
+				Names:    []string{"a", "b"},
+				Weights:  Weights{{From: 0, To: 1}: 1.5, {From: 1, To: 0}: 1.5},
+			},
+			false,
+		},
+		{
+			"GraphML directed edge",
+			ReadGraphML,
+			`<graphml><graph edgedefault="undirected">
+				<node id="0"/><node id="1"/>
+				<edge source="0" target="1" directed="true"/>
+			</graph></graphml>`,
+			&Network{Template: routers.Template{{1}, {}}},
+			false,
+		},
+		{"GraphML no nodes", ReadGraphML, `<graphml><graph edgedefault="undirected"/></graphml>`, nil, true},
+		{
+			"GraphML bad weight",
+			ReadGraphML,
+			`<graphml><key id="w" for="edge" attr.name="cost"/><graph>
+				<edge source="0" target="1"><data key="w">far</data></edge>
+			</graph></graphml>`,
+			nil,
+			true,
+		},
+		{"DOT graph", ReadDOT, "graph { 0 -- 1 -- 2 }", &Network{Template: line}, false},
+		{
+			"DOT labels, weights and comments",
+			ReadDOT,
+			"graph g {\n  // routers\n  a [label=\"east\"]; b;\n  a -- b [weight=2]\n  /* default attributes are ignored */\n  edge [color=red]\n}",
+			&Network{
+				Template: routers.Template{{1}, {0}},
+				Names:    []string{"east", "b"},
+				Weights:  Weights{{From: 0, To: 1}: 2, {From: 1, To: 0}: 2},
+			},
+			false,
+		},
+		{
+			"DOT digraph with subgraph",
+			ReadDOT,
+			"digraph { 0 -> 1 [cost=4]; subgraph s { 1 -> 2 } }",
+			&Network{Template: routers.Template{{1}, {2}, {}}, Weights: Weights{{From: 0, To: 1}: 4}},
+			false,
+		},
+		{"DOT bad weight", ReadDOT, "graph { a -- b [weight=heavy] }", nil, true},
+		{"DOT unterminated", ReadDOT, "graph { a -- b", nil, true},
+		{"DOT empty graph", ReadDOT, "graph { }", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.read(strings.NewReader(tt.src))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("read %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,341 @@
+package topology
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+
+	"routers"
+)
+
+// maxAttempts ... Number of times rejection sampled generators retry before giving up, scaled by the size of the
+// graph for those repairing it a link at a time
+const maxAttempts = 100
+
+// ErrDisconnected ... Returned when a connected graph was requested but could not be produced
+var ErrDisconnected = errors.New("unable to generate a connected topology")
+
+// ErrUnpaired ... Returned when link ends could not be paired without self loops or repeated links
+var ErrUnpaired = errors.New("unable to pair link ends without self loops or repeated links")
+
+// RandomOptions ... Options shared by the random graph generators
+type RandomOptions struct {
+	Seed      int64
+	Connected bool // Guarantee every router can reach every other router
+}
+
+// Positions ... X, Y position of each router within the unit square, indexed by RouterId
+type Positions [][2]float64
+
+// ErdosRenyi ... G(n, p) random graph, each possible link present independently with probability {p}
+func ErdosRenyi(n uint, p float64, opts RandomOptions) (routers.Template, error) {
+	if _, err := routerCount("Erdos_Renyi", Params{Size: n}); err != nil {
+		return nil, err
+	}
+	if p < 0 || p > 1 {
+		return nil, fmt.Errorf("link probability must be within [0, 1] (have %v)", p)
+	}
+	rng := rand.New(rand.NewSource(opts.Seed))
+	t := newTemplate(n)
+	for i := uint(0); i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			if rng.Float64() < p {
+				connect(t, routers.RouterId(i), routers.RouterId(j))
+			}
+		}
+	}
+	if opts.Connected {
+		joinComponents(t, func(a []routers.RouterId, b []routers.RouterId) (routers.RouterId, routers.RouterId) {
+			return a[rng.Intn(len(a))], b[rng.Intn(len(b))]
+		})
+	}
+	return t, nil
+}
+
+// BarabasiAlbert ... Scale-free graph grown by preferential attachment, each new router linking to {m} existing routers
+// chosen in proportion to their degree. Always connected
+func BarabasiAlbert(n uint, m uint, opts RandomOptions) (routers.Template, error) {
+	if _, err := routerCount("Barabasi_Albert", Params{Size: n}); err != nil {
+		return nil, err
+	}
+	if m == 0 || m >= n {
+		return nil, fmt.Errorf("attachment count must be within [1, %v) (have %v)", n, m)
+	}
+	rng := rand.New(rand.NewSource(opts.Seed))
+	t := newTemplate(n)
+	// Each router appears once per link end, so sampling uniformly from it is proportional to degree
+	ends := make([]routers.RouterId, 0, 2*n*m)
+	for i := uint(0); i <= m; i++ {
+		for j := i + 1; j <= m; j++ {
+			connect(t, routers.RouterId(i), routers.RouterId(j))
+			ends = append(ends, routers.RouterId(i), routers.RouterId(j))
+		}
+	}
+	for i := m + 1; i < n; i++ {
+		// Kept in the order chosen, iterating a map would make the graph differ between runs with the same seed
+		chosen := make([]routers.RouterId, 0, m)
+		seen := make(map[routers.RouterId]bool, m)
+		for uint(len(chosen)) < m {
+			if target := ends[rng.Intn(len(ends))]; !seen[target] {
+				seen[target] = true
+				chosen = append(chosen, target)
+			}
+		}
+		for _, target := range chosen {
+			connect(t, routers.RouterId(i), target)
+			ends = append(ends, routers.RouterId(i), target)
+		}
+	}
+	return t, nil
+}
+
+// WattsStrogatz ... Small-world graph, a ring lattice of degree {k} with each link rewired with probability {beta}
+func WattsStrogatz(n uint, k uint, beta float64, opts RandomOptions) (routers.Template, error) {
+	if _, err := routerCount("Watts_Strogatz", Params{Size: n}); err != nil {
+		return nil, err
+	}
+	if k%2 != 0 || k >= n {
+		return nil, fmt.Errorf("lattice degree must be even and less than %v (have %v)", n, k)
+	}
+	if beta < 0 || beta > 1 {
+		return nil, fmt.Errorf("rewiring probability must be within [0, 1] (have %v)", beta)
+	}
+	rng := rand.New(rand.NewSource(opts.Seed))
+	t := newTemplate(n)
+	for i := uint(0); i < n; i++ {
+		for j := uint(1); j <= k/2; j++ {
+			connect(t, routers.RouterId(i), routers.RouterId((i+j)%n))
+		}
+	}
+	for j := uint(1); j <= k/2; j++ {
+		for i := uint(0); i < n; i++ {
+			a, b := routers.RouterId(i), routers.RouterId((i+j)%n)
+			if rng.Float64() >= beta || uint(len(t[a])) >= n-1 {
+				continue
+			}
+			target := routers.RouterId(rng.Intn(int(n)))
+			for target == a || linked(t, a, target) {
+				target = routers.RouterId(rng.Intn(int(n)))
+			}
+			disconnect(t, a, b)
+			connect(t, a, target)
+		}
+	}
+	if opts.Connected {
+		joinComponents(t, func(a []routers.RouterId, b []routers.RouterId) (routers.RouterId, routers.RouterId) {
+			return a[rng.Intn(len(a))], b[rng.Intn(len(b))]
+		})
+	}
+	return t, nil
+}
+
+// RandomRegular ... Randomly paired graph where every router has exactly {d} links
+func RandomRegular(n uint, d uint, opts RandomOptions) (routers.Template, error) {
+	if _, err := routerCount("Random_Regular", Params{Size: n}); err != nil {
+		return nil, err
+	}
+	if d >= n || (n*d)%2 != 0 {
+		return nil, fmt.Errorf("degree must be less than %v with an even number of link ends (have %v)", n, d)
+	}
+	rng := rand.New(rand.NewSource(opts.Seed))
+	var t routers.Template
+	var ok bool
+	if 2*d > n-1 {
+		// Dense graphs are paired as their sparser complement, too few routers are left unlinked to repair with
+		if t, ok = pairStubs(n, n-1-d, rng); ok {
+			t = complement(t)
+		}
+	} else {
+		t, ok = pairStubs(n, d, rng)
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w: %v-regular graph of %v routers", ErrUnpaired, d, n)
+	}
+	if opts.Connected && !joinRegular(t, rng) {
+		return nil, ErrDisconnected
+	}
+	return t, nil
+}
+
+// pairStubs ... Configuration model, randomly pairing {d} link ends per router. Pairs that would form a self loop or
+// repeat a link are repaired by exchanging ends with a randomly chosen link, (a, b) and (c, e) becoming (a, c) and
+// (b, e), rather than starting over, as the chance of a clean pairing vanishes as the degree grows
+func pairStubs(n uint, d uint, rng *rand.Rand) (routers.Template, bool) {
+	stubs := make([]routers.RouterId, 0, n*d)
+	for i := uint(0); i < n; i++ {
+		for j := uint(0); j < d; j++ {
+			stubs = append(stubs, routers.RouterId(i))
+		}
+	}
+	rng.Shuffle(len(stubs), func(i, j int) { stubs[i], stubs[j] = stubs[j], stubs[i] })
+	t := newTemplate(n)
+	pairs := make([][2]routers.RouterId, 0, len(stubs)/2)
+	conflicts := make([][2]routers.RouterId, 0)
+	for i := 0; i < len(stubs); i += 2 {
+		a, b := stubs[i], stubs[i+1]
+		if a == b || linked(t, a, b) {
+			conflicts = append(conflicts, [2]routers.RouterId{a, b})
+			continue
+		}
+		connect(t, a, b)
+		pairs = append(pairs, [2]routers.RouterId{a, b})
+	}
+	for _, conflict := range conflicts {
+		a, b := conflict[0], conflict[1]
+		repaired := false
+		for attempt := 0; attempt < maxAttempts*len(stubs) && len(pairs) > 0 && !repaired; attempt++ {
+			k := rng.Intn(len(pairs))
+			c, e := pairs[k][0], pairs[k][1]
+			if rng.Intn(2) == 0 {
+				c, e = e, c
+			}
+			if a == c || b == e || linked(t, a, c) || linked(t, b, e) {
+				continue
+			}
+			disconnect(t, c, e)
+			connect(t, a, c)
+			connect(t, b, e)
+			pairs[k] = [2]routers.RouterId{a, c}
+			pairs = append(pairs, [2]routers.RouterId{b, e})
+			repaired = true
+		}
+		if !repaired {
+			return nil, false
+		}
+	}
+	return t, true
+}
+
+// complement ... Template linking exactly the routers {t} doesn't
+func complement(t routers.Template) routers.Template {
+	c := newTemplate(uint(len(t)))
+	for i := range t {
+		for j := i + 1; j < len(t); j++ {
+			if !linked(t, routers.RouterId(i), routers.RouterId(j)) {
+				connect(c, routers.RouterId(i), routers.RouterId(j))
+			}
+		}
+	}
+	return c
+}
+
+// joinRegular ... Join the components of a regular graph without changing any router's degree, exchanging the ends
+// of a random link in each of two components so that (a, b) and (c, e) become (a, c) and (b, e). Returns whether the
+// graph was left connected, which it can't be when every exchange splits a bridge on both sides
+func joinRegular(t routers.Template, rng *rand.Rand) bool {
+	for attempt := 0; attempt < maxAttempts*len(t); attempt++ {
+		parts := components(t)
+		if len(parts) == 1 {
+			return true
+		}
+		x, y := parts[0], parts[1+rng.Intn(len(parts)-1)]
+		a, c := x[rng.Intn(len(x))], y[rng.Intn(len(y))]
+		if len(t[a]) == 0 || len(t[c]) == 0 {
+			return false
+		}
+		b, e := t[a][rng.Intn(len(t[a]))], t[c][rng.Intn(len(t[c]))]
+		disconnect(t, a, b)
+		disconnect(t, c, e)
+		connect(t, a, c)
+		connect(t, b, e)
+	}
+	return len(components(t)) == 1
+}
+
+// RandomGeometric ... Routers placed uniformly in the unit square, linked when within {radius} of each other.
+// Connected graphs are found by placing the routers again, never by bridging components with longer links, so every
+// graph returned is a unit disk graph as geographic routing's planarisation requires
+func RandomGeometric(n uint, radius float64, opts RandomOptions) (routers.Template, Positions, error) {
+	if _, err := routerCount("Random_Geometric", Params{Size: n}); err != nil {
+		return nil, nil, err
+	}
+	if radius < 0 {
+		return nil, nil, fmt.Errorf("radius must not be negative (have %v)", radius)
+	}
+	rng := rand.New(rand.NewSource(opts.Seed))
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		positions := make(Positions, n)
+		for i := range positions {
+			positions[i] = [2]float64{rng.Float64(), rng.Float64()}
+		}
+		t := newTemplate(n)
+		for i := uint(0); i < n; i++ {
+			for j := i + 1; j < n; j++ {
+				if distance(positions[i], positions[j]) <= radius {
+					connect(t, routers.RouterId(i), routers.RouterId(j))
+				}
+			}
+		}
+		if !opts.Connected || len(components(t)) == 1 {
+			return t, positions, nil
+		}
+	}
+	return nil, nil, ErrDisconnected
+}
+
+// distance ... Euclidean distance between two positions
+func distance(a [2]float64, b [2]float64) float64 {
+	return math.Hypot(a[0]-b[0], a[1]-b[1])
+}
+
+// linked ... Check if {a} lists {b} as a neighbour
+func linked(t routers.Template, a routers.RouterId, b routers.RouterId) bool {
+	for _, n := range t[a] {
+		if n == b {
+			return true
+		}
+	}
+	return false
+}
+
+// disconnect ... Remove the symmetric link between {a} and {b}
+func disconnect(t routers.Template, a routers.RouterId, b routers.RouterId) {
+	remove := func(from routers.RouterId, id routers.RouterId) {
+		for i, n := range t[from] {
+			if n == id {
+				t[from] = append(t[from][:i], t[from][i+1:]...)
+				return
+			}
+		}
+	}
+	remove(a, b)
+	remove(b, a)
+}
+
+// components ... Partition the routers into connected components
+func components(t routers.Template) [][]routers.RouterId {
+	seen := make([]bool, len(t))
+	parts := make([][]routers.RouterId, 0)
+	for start := range t {
+		if seen[start] {
+			continue
+		}
+		seen[start] = true
+		part := []routers.RouterId{routers.RouterId(start)}
+		for i := 0; i < len(part); i++ {
+			for _, n := range t[part[i]] {
+				if !seen[n] {
+					seen[n] = true
+					part = append(part, n)
+				}
+			}
+		}
+		parts = append(parts, part)
+	}
+	return parts
+}
+
+// joinComponents ... Link every component to the growing first component, using {choose} to pick the routers to join
+func joinComponents(t routers.Template, choose func(a []routers.RouterId, b []routers.RouterId) (routers.RouterId, routers.RouterId)) {
+	parts := components(t)
+	if len(parts) < 2 {
+		return
+	}
+	joined := parts[0]
+	for _, part := range parts[1:] {
+		a, b := choose(joined, part)
+		connect(t, a, b)
+		joined = append(joined, part...)
+	}
+}
@@ -0,0 +1,187 @@
+package topology
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"routers"
+)
+
+func TestRandomGenerators(t *testing.T) {
+	tests := []struct {
+		name      string
+		generate  func(opts RandomOptions) (routers.Template, error)
+		routers   int
+		links     int // Expected link count, -1 when it varies with the seed
+		degree    int // Expected degree of every router, -1 when it varies
+		connected bool
+	}{
+		{"Erdos_Renyi", func(o RandomOptions) (routers.Template, error) { return ErdosRenyi(20, 0.2, o) }, 20, -1, -1, false},
+		{"Erdos_Renyi connected", func(o RandomOptions) (routers.Template, error) {
+			o.Connected = true
+			return ErdosRenyi(20, 0.05, o)
+		}, 20, -1, -1, true},
+		{"Erdos_Renyi complete", func(o RandomOptions) (routers.Template, error) { return ErdosRenyi(6, 1, o) }, 6, 15, 5, true},
+		{"Barabasi_Albert", func(o RandomOptions) (routers.Template, error) { return BarabasiAlbert(20, 2, o) }, 20, 3 + 17*2, -1, true},
+		{"Watts_Strogatz lattice", func(o RandomOptions) (routers.Template, error) { return WattsStrogatz(12, 4, 0, o) }, 12, 24, 4, true},
+		{"Watts_Strogatz rewired", func(o RandomOptions) (routers.Template, error) { return WattsStrogatz(12, 4, 0.3, o) }, 12, 24, -1, false},
+		{"Random_Regular", func(o RandomOptions) (routers.Template, error) { return RandomRegular(10, 3, o) }, 10, 15, 3, false},
+		{"Random_Regular connected", func(o RandomOptions) (routers.Template, error) {
+			o.Connected = true
+			return RandomRegular(10, 3, o)
+		}, 10, 15, 3, true},
+		{"Random_Geometric connected", func(o RandomOptions) (routers.Template, error) {
+			o.Connected = true
+			tmpl, _, err := RandomGeometric(15, 0.4, o)
+			return tmpl, err
+		}, 15, -1, -1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for seed := int64(1); seed <= 5; seed++ {
+				got, err := tt.generate(RandomOptions{Seed: seed})
+				if err != nil {
+					t.Fatalf("seed %v: %v", seed, err)
+				}
+				if len(got) != tt.routers {
+					t.Errorf("seed %v: %v routers, want %v", seed, len(got), tt.routers)
+				}
+				if problems := got.Validate(); problems != nil && (tt.connected || !isolatedOnly(problems)) {
+					t.Errorf("seed %v: invalid template: %v", seed, problems)
+				}
+				links := linkSet(t, got)
+				if tt.links >= 0 && len(links) != tt.links {
+					t.Errorf("seed %v: %v links, want %v", seed, len(links), tt.links)
+				}
+				for i, neighbours := range got {
+					if tt.degree >= 0 && len(neighbours) != tt.degree {
+						t.Errorf("seed %v: router %v has degree %v, want %v", seed, i, len(neighbours), tt.degree)
+					}
+				}
+				if tt.connected && len(components(got)) != 1 {
+					t.Errorf("seed %v: %v components, want 1", seed, len(components(got)))
+				}
+				again, _ := tt.generate(RandomOptions{Seed: seed})
+				if !reflect.DeepEqual(links, linkSet(t, again)) {
+					t.Errorf("seed %v: links differ between runs, %v then %v", seed, links, linkSet(t, again))
+				}
+			}
+		})
+	}
+}
+
+// isolatedOnly ... Whether every problem is an isolated router, as graphs not required to be connected may have
+func isolatedOnly(problems routers.Problems) bool {
+	for _, p := range problems {
+		if p.Kind != routers.IsolatedRouter {
+			return false
+		}
+	}
+	return true
+}
+
+func TestRandomGeometricUnitDisk(t *testing.T) {
+	tests := []struct {
+		name      string
+		n         uint
+		radius    float64
+		connected bool
+	}{
+		{"sparse", 20, 0.2, false},
+		{"connected", 20, 0.35, true},
+		{"no links", 5, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, positions, err := RandomGeometric(tt.n, tt.radius, RandomOptions{Seed: 3, Connected: tt.connected})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := range got {
+				for j := i + 1; j < len(got); j++ {
+					a, b := routers.RouterId(i), routers.RouterId(j)
+					if near := distance(positions[a], positions[b]) <= tt.radius; near != linked(got, a, b) {
+						t.Errorf("routers %v and %v at distance %v linked %v with radius %v",
+							a, b, distance(positions[a], positions[b]), linked(got, a, b), tt.radius)
+					}
+				}
+			}
+			if tt.connected && len(components(got)) != 1 {
+				t.Errorf("%v components, want 1", len(components(got)))
+			}
+		})
+	}
+}
+
+func TestRandomRegular(t *testing.T) {
+	tests := []struct {
+		n         uint
+		d         uint
+		connected bool
+		err       error
+	}{
+		{50, 5, true, nil},
+		{50, 6, true, nil},
+		{50, 8, true, nil},
+		{50, 10, true, nil},
+		{50, 10, false, nil},
+		{12, 11, true, nil},
+		{12, 9, true, nil},
+		{30, 20, false, nil},
+		{20, 2, true, nil},
+		{2, 1, true, nil},
+		{8, 1, true, ErrDisconnected},
+		{8, 0, true, ErrDisconnected},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%v routers of degree %v", tt.n, tt.d), func(t *testing.T) {
+			for seed := int64(1); seed <= 20; seed++ {
+				got, err := RandomRegular(tt.n, tt.d, RandomOptions{Seed: seed, Connected: tt.connected})
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("seed %v: error = %v, want %v", seed, err, tt.err)
+				}
+				if err != nil {
+					continue
+				}
+				if links := len(linkSet(t, got)); links != int(tt.n*tt.d/2) {
+					t.Errorf("seed %v: %v links, want %v", seed, links, tt.n*tt.d/2)
+				}
+				for i, neighbours := range got {
+					if len(neighbours) != int(tt.d) {
+						t.Errorf("seed %v: router %v has degree %v, want %v", seed, i, len(neighbours), tt.d)
+					}
+				}
+				if tt.connected && len(components(got)) != 1 {
+					t.Errorf("seed %v: %v components, want 1", seed, len(components(got)))
+				}
+			}
+		})
+	}
+}
+
+func TestRandomGeneratorErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		generate func() error
+	}{
+		{"no routers", func() error { _, err := ErdosRenyi(0, 0.5, RandomOptions{}); return err }},
+		{"probability out of range", func() error { _, err := ErdosRenyi(5, 1.5, RandomOptions{}); return err }},
+		{"attachment too large", func() error { _, err := BarabasiAlbert(5, 5, RandomOptions{}); return err }},
+		{"odd lattice degree", func() error { _, err := WattsStrogatz(10, 3, 0.1, RandomOptions{}); return err }},
+		{"odd link ends", func() error { _, err := RandomRegular(5, 3, RandomOptions{}); return err }},
+		{"negative radius", func() error { _, _, err := RandomGeometric(5, -1, RandomOptions{}); return err }},
+		{"unreachable connection", func() error {
+			_, _, err := RandomGeometric(30, 0.01, RandomOptions{Connected: true})
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.generate(); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,357 @@
+// Package topology ... Generators for router network templates of common interconnect topologies
+package topology
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"routers"
+)
+
+// ErrNoRouters ... Returned when the requested parameters would produce an empty network
+var ErrNoRouters = errors.New("topology has zero routers")
+
+// Params ... Parameters shared by the topology generators
+type Params struct {
+	Size      uint
+	Dimension uint
+
+	// Random graph parameters
+	Probability float64 // Link probability (Erdos_Renyi) or rewiring probability (Watts_Strogatz)
+	Degree      uint    // Attachments (Barabasi_Albert), lattice degree (Watts_Strogatz) or degree (Random_Regular)
+	Radius      float64 // Link radius within the unit square (Random_Geometric)
+	Seed        int64
+	Connected   bool
+}
+
+// Names ... Topologies supported by Generate
+var Names = []string{
+	"Line",
+	"Ring",
+	"Star",
+	"Fully_Connected",
+	"Mesh",
+	"Torus",
+	"Hypercube",
+	"Cube_Connected_Cycles",
+	"Butterfly",
+	"Wrap_Around_Butterfly",
+	"Erdos_Renyi",
+	"Barabasi_Albert",
+	"Watts_Strogatz",
+	"Random_Regular",
+	"Random_Geometric",
+}
+
+// Count ... Calculate the number of routers the named topology would contain, without generating it
+func Count(name string, p Params) (*big.Int, error) {
+	size := new(big.Int).SetUint64(uint64(p.Size))
+	dim := new(big.Int).SetUint64(uint64(p.Dimension))
+	cube := new(big.Int).Lsh(big.NewInt(1), p.Dimension)
+	switch name {
+	case "Line", "Ring", "Star", "Fully_Connected",
+		"Erdos_Renyi", "Barabasi_Albert", "Watts_Strogatz", "Random_Regular", "Random_Geometric":
+		return size, nil
+	case "Mesh", "Torus":
+		return new(big.Int).Exp(size, dim, nil), nil
+	case "Hypercube":
+		return cube, nil
+	case "Cube_Connected_Cycles", "Wrap_Around_Butterfly":
+		return new(big.Int).Mul(dim, cube), nil
+	case "Butterfly":
+		return new(big.Int).Mul(new(big.Int).Add(dim, big.NewInt(1)), cube), nil
+	default:
+		return nil, fmt.Errorf("unsupported topology %s", name)
+	}
+}
+
+// Generate ... Build the template for the named topology
+func Generate(name string, p Params) (routers.Template, error) {
+	if _, err := routerCount(name, p); err != nil {
+		return nil, err
+	}
+	switch name {
+	case "Line":
+		return Line(p.Size)
+	case "Ring":
+		return Ring(p.Size)
+	case "Star":
+		return Star(p.Size)
+	case "Fully_Connected":
+		return FullyConnected(p.Size)
+	case "Mesh":
+		return Mesh(p.Size, p.Dimension)
+	case "Torus":
+		return Torus(p.Size, p.Dimension)
+	case "Hypercube":
+		return Hypercube(p.Dimension)
+	case "Cube_Connected_Cycles":
+		return CubeConnectedCycles(p.Dimension)
+	case "Butterfly":
+		return Butterfly(p.Dimension)
+	case "Erdos_Renyi":
+		return ErdosRenyi(p.Size, p.Probability, p.randomOptions())
+	case "Barabasi_Albert":
+		return BarabasiAlbert(p.Size, p.Degree, p.randomOptions())
+	case "Watts_Strogatz":
+		return WattsStrogatz(p.Size, p.Degree, p.Probability, p.randomOptions())
+	case "Random_Regular":
+		return RandomRegular(p.Size, p.Degree, p.randomOptions())
+	case "Random_Geometric":
+		t, _, err := RandomGeometric(p.Size, p.Radius, p.randomOptions())
+		return t, err
+	case "Wrap_Around_Butterfly":
+		return WrapAroundButterfly(p.Dimension)
+	default:
+		return nil, fmt.Errorf("unsupported topology %s", name)
+	}
+}
+
+// routerCount ... Number of routers the named topology would contain, failing should it have none or more than a
+// template can index. Every generator checks its parameters with it before allocating, so none overflow
+func routerCount(name string, p Params) (uint, error) {
+	count, err := Count(name, p)
+	if err != nil {
+		return 0, err
+	}
+	if count.Sign() == 0 {
+		return 0, ErrNoRouters
+	}
+	if !count.IsInt64() || count.Int64() > int64(^uint(0)>>1) {
+		return 0, fmt.Errorf("topology %s would contain %v routers", name, count)
+	}
+	return uint(count.Int64()), nil
+}
+
+// randomOptions ... Options for the random graph generators
+func (p Params) randomOptions() RandomOptions {
+	return RandomOptions{Seed: p.Seed, Connected: p.Connected}
+}
+
+// newTemplate ... Allocate a template of {n} routers with no links
+func newTemplate(n uint) routers.Template {
+	t := make(routers.Template, n)
+	for i := range t {
+		t[i] = make([]routers.RouterId, 0)
+	}
+	return t
+}
+
+// connect ... Add a symmetric link between {a} and {b}, ignoring self loops and existing links
+func connect(t routers.Template, a routers.RouterId, b routers.RouterId) {
+	if a == b {
+		return
+	}
+	for _, n := range t[a] {
+		if n == b {
+			return
+		}
+	}
+	t[a] = append(t[a], b)
+	t[b] = append(t[b], a)
+}
+
+// Line ... Routers connected in a chain with open ends
+func Line(size uint) (routers.Template, error) {
+	if _, err := routerCount("Line", Params{Size: size}); err != nil {
+		return nil, err
+	}
+	t := newTemplate(size)
+	for i := uint(1); i < size; i++ {
+		connect(t, routers.RouterId(i-1), routers.RouterId(i))
+	}
+	return t, nil
+}
+
+// Ring ... Routers connected in a chain with the ends joined
+func Ring(size uint) (routers.Template, error) {
+	t, err := Line(size)
+	if err != nil {
+		return nil, err
+	}
+	connect(t, routers.RouterId(size-1), 0)
+	return t, nil
+}
+
+// Star ... Router 0 connected to every other router
+func Star(size uint) (routers.Template, error) {
+	if _, err := routerCount("Star", Params{Size: size}); err != nil {
+		return nil, err
+	}
+	t := newTemplate(size)
+	for i := uint(1); i < size; i++ {
+		connect(t, 0, routers.RouterId(i))
+	}
+	return t, nil
+}
+
+// FullyConnected ... Every router connected to every other router
+func FullyConnected(size uint) (routers.Template, error) {
+	if _, err := routerCount("Fully_Connected", Params{Size: size}); err != nil {
+		return nil, err
+	}
+	t := newTemplate(size)
+	for i := uint(0); i < size; i++ {
+		for j := i + 1; j < size; j++ {
+			connect(t, routers.RouterId(i), routers.RouterId(j))
+		}
+	}
+	return t, nil
+}
+
+// Coordinates ... Integer position of each router within a grid, indexed by RouterId
+type Coordinates [][]int
+
+// Mesh ... k-ary n-dimensional mesh, a grid of side {size} in {dimension} dimensions without wrap around
+func Mesh(size uint, dimension uint) (routers.Template, error) {
+	t, _, err := MeshWithCoordinates(size, dimension)
+	return t, err
+}
+
+// MeshWithCoordinates ... Mesh alongside the grid coordinates of each router
+func MeshWithCoordinates(size uint, dimension uint) (routers.Template, Coordinates, error) {
+	return grid(size, dimension, false)
+}
+
+// Torus ... k-ary n-cube, a grid of side {size} in {dimension} dimensions with wrap around links
+func Torus(size uint, dimension uint) (routers.Template, error) {
+	t, _, err := TorusWithCoordinates(size, dimension)
+	return t, err
+}
+
+// TorusWithCoordinates ... Torus alongside the grid coordinates of each router
+func TorusWithCoordinates(size uint, dimension uint) (routers.Template, Coordinates, error) {
+	return grid(size, dimension, true)
+}
+
+// grid ... Routers at integer coordinates in [0, size)^dimension, linked where they differ by one
+// in exactly one dimension. Router IDs enumerate the coordinates with dimension 0 varying fastest
+func grid(size uint, dimension uint, wrap bool) (routers.Template, Coordinates, error) {
+	count, err := routerCount("Mesh", Params{Size: size, Dimension: dimension})
+	if err != nil {
+		return nil, nil, err
+	}
+	t := newTemplate(count)
+	coords := make(Coordinates, count)
+	for i := uint(0); i < count; i++ {
+		coords[i] = make([]int, dimension)
+		stride := uint(1)
+		for d := uint(0); d < dimension; d++ {
+			digit := (i / stride) % size
+			coords[i][d] = int(digit)
+			if digit+1 < size {
+				connect(t, routers.RouterId(i), routers.RouterId(i+stride))
+			} else if wrap {
+				connect(t, routers.RouterId(i), routers.RouterId(i-digit*stride))
+			}
+			stride *= size
+		}
+	}
+	return t, coords, nil
+}
+
+// Hypercube ... 2^dimension routers, linked where their IDs differ in exactly one bit
+func Hypercube(dimension uint) (routers.Template, error) {
+	t, _, err := HypercubeWithCoordinates(dimension)
+	return t, err
+}
+
+// HypercubeWithCoordinates ... Hypercube alongside the coordinates of each router, the bits of its ID
+func HypercubeWithCoordinates(dimension uint) (routers.Template, Coordinates, error) {
+	count, err := routerCount("Hypercube", Params{Dimension: dimension})
+	if err != nil {
+		return nil, nil, err
+	}
+	t := newTemplate(count)
+	coords := make(Coordinates, count)
+	for i := uint(0); i < count; i++ {
+		coords[i] = make([]int, dimension)
+		for d := uint(0); d < dimension; d++ {
+			coords[i][d] = int(i>>d) & 1
+			connect(t, routers.RouterId(i), routers.RouterId(i^(1<<d)))
+		}
+	}
+	return t, coords, nil
+}
+
+// GenerateGrid ... Build the template for a Mesh, Torus or Hypercube alongside its coordinates, for coordinate
+// based routing. Hypercubes are meshes of side 2, so only a torus wraps around
+func GenerateGrid(name string, p Params) (routers.Template, *routers.Grid, error) {
+	var t routers.Template
+	var coords Coordinates
+	var err error
+	switch name {
+	case "Mesh":
+		t, coords, err = MeshWithCoordinates(p.Size, p.Dimension)
+	case "Torus":
+		t, coords, err = TorusWithCoordinates(p.Size, p.Dimension)
+	case "Hypercube":
+		t, coords, err = HypercubeWithCoordinates(p.Dimension)
+	default:
+		return nil, nil, fmt.Errorf("topology %s has no grid coordinates (expected Mesh, Torus or Hypercube)", name)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return t, &routers.Grid{Coordinates: coords, Wrap: name == "Torus"}, nil
+}
+
+// CubeConnectedCycles ... Hypercube with each corner replaced by a cycle of {dimension} routers.
+// Router (w, i) has ID w*dimension + i, linking to (w, i±1) around its cycle and (w xor 2^i, i) across the cube
+func CubeConnectedCycles(dimension uint) (routers.Template, error) {
+	count, err := routerCount("Cube_Connected_Cycles", Params{Dimension: dimension})
+	if err != nil {
+		return nil, err
+	}
+	corners := uint(1) << dimension
+	t := newTemplate(count)
+	id := func(w uint, i uint) routers.RouterId {
+		return routers.RouterId(w*dimension + i)
+	}
+	for w := uint(0); w < corners; w++ {
+		for i := uint(0); i < dimension; i++ {
+			connect(t, id(w, i), id(w, (i+1)%dimension))
+			connect(t, id(w, i), id(w^(1<<i), i))
+		}
+	}
+	return t, nil
+}
+
+// Butterfly ... {dimension}+1 levels of 2^dimension routers. Router (l, w) has ID l*2^dimension + w,
+// linking to (l+1, w) and (l+1, w xor 2^l)
+func Butterfly(dimension uint) (routers.Template, error) {
+	count, err := routerCount("Butterfly", Params{Dimension: dimension})
+	if err != nil {
+		return nil, err
+	}
+	rows := uint(1) << dimension
+	t := newTemplate(count)
+	for l := uint(0); l < dimension; l++ {
+		for w := uint(0); w < rows; w++ {
+			from := routers.RouterId(l*rows + w)
+			connect(t, from, routers.RouterId((l+1)*rows+w))
+			connect(t, from, routers.RouterId((l+1)*rows+(w^(1<<l))))
+		}
+	}
+	return t, nil
+}
+
+// WrapAroundButterfly ... Butterfly with the first and last levels merged into {dimension} levels of 2^dimension routers
+func WrapAroundButterfly(dimension uint) (routers.Template, error) {
+	count, err := routerCount("Wrap_Around_Butterfly", Params{Dimension: dimension})
+	if err != nil {
+		return nil, err
+	}
+	rows := uint(1) << dimension
+	t := newTemplate(count)
+	for l := uint(0); l < dimension; l++ {
+		next := (l + 1) % dimension
+		for w := uint(0); w < rows; w++ {
+			from := routers.RouterId(l*rows + w)
+			connect(t, from, routers.RouterId(next*rows+w))
+			connect(t, from, routers.RouterId(next*rows+(w^(1<<l))))
+		}
+	}
+	return t, nil
+}
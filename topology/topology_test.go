@@ -0,0 +1,196 @@
+package topology
+
+import (
+	"errors"
+	"testing"
+
+	"routers"
+)
+
+// linkSet ... Collect the undirected links of {t}, failing on self loops, duplicates or one way links
+func linkSet(tb testing.TB, t routers.Template) map[[2]routers.RouterId]bool {
+	tb.Helper()
+	links := make(map[[2]routers.RouterId]bool)
+	for a, ns := range t {
+		seen := make(map[routers.RouterId]bool)
+		for _, b := range ns {
+			if int(b) == a {
+				tb.Fatalf("router %d links to itself", a)
+			}
+			if seen[b] {
+				tb.Fatalf("router %d links to %d twice", a, b)
+			}
+			seen[b] = true
+			back := false
+			for _, n := range t[b] {
+				back = back || int(n) == a
+			}
+			if !back {
+				tb.Fatalf("link %d-%d is one way", a, b)
+			}
+			if routers.RouterId(a) < b {
+				links[[2]routers.RouterId{routers.RouterId(a), b}] = true
+			}
+		}
+	}
+	return links
+}
+
+func TestGenerate(t *testing.T) {
+	tests := []struct {
+		name      string
+		params    Params
+		routers   int
+		links     int
+		minDegree int
+		maxDegree int
+		err       error
+	}{
+		{"Line", Params{Size: 5}, 5, 4, 1, 2, nil},
+		{"Ring", Params{Size: 5}, 5, 5, 2, 2, nil},
+		{"Ring", Params{Size: 2}, 2, 1, 1, 1, nil},
+		{"Star", Params{Size: 5}, 5, 4, 1, 4, nil},
+		{"Fully_Connected", Params{Size: 5}, 5, 10, 4, 4, nil},
+		{"Mesh", Params{Size: 3, Dimension: 2}, 9, 12, 2, 4, nil},
+		{"Mesh", Params{Size: 4, Dimension: 1}, 4, 3, 1, 2, nil},
+		{"Torus", Params{Size: 3, Dimension: 2}, 9, 18, 4, 4, nil},
+		{"Hypercube", Params{Dimension: 3}, 8, 12, 3, 3, nil},
+		{"Cube_Connected_Cycles", Params{Dimension: 3}, 24, 36, 3, 3, nil},
+		{"Butterfly", Params{Dimension: 2}, 12, 16, 2, 4, nil},
+		{"Wrap_Around_Butterfly", Params{Dimension: 3}, 24, 48, 4, 4, nil},
+		{"Line", Params{}, 0, 0, 0, 0, ErrNoRouters},
+		{"Torus", Params{Size: 0, Dimension: 2}, 0, 0, 0, 0, ErrNoRouters},
+		{"Cube_Connected_Cycles", Params{}, 0, 0, 0, 0, ErrNoRouters},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := Generate(tt.name, tt.params)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Generate() error = %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			if len(tmpl) != tt.routers {
+				t.Errorf("Generate() has %d routers, want %d", len(tmpl), tt.routers)
+			}
+			count, _ := Count(tt.name, tt.params)
+			if count.Int64() != int64(len(tmpl)) {
+				t.Errorf("Count() = %v, generated %d routers", count, len(tmpl))
+			}
+			if links := len(linkSet(t, tmpl)); links != tt.links {
+				t.Errorf("Generate() has %d links, want %d", links, tt.links)
+			}
+			for r, ns := range tmpl {
+				if len(ns) < tt.minDegree || len(ns) > tt.maxDegree {
+					t.Errorf("router %d has degree %d, want %d to %d", r, len(ns), tt.minDegree, tt.maxDegree)
+				}
+			}
+		})
+	}
+}
+
+func TestGridCoordinates(t *testing.T) {
+	tests := []struct {
+		name string
+		wrap bool
+	}{
+		{"mesh", false},
+		{"torus", true},
+	}
+	const size, dimension = 4, 3
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var tmpl routers.Template
+			var coords Coordinates
+			var err error
+			if tt.wrap {
+				tmpl, coords, err = TorusWithCoordinates(size, dimension)
+			} else {
+				tmpl, coords, err = MeshWithCoordinates(size, dimension)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			links := linkSet(t, tmpl)
+			for a := range coords {
+				for b := a + 1; b < len(coords); b++ {
+					differ, apart := 0, 0
+					for d := range coords[a] {
+						if delta := coords[a][d] - coords[b][d]; delta != 0 {
+							differ++
+							if delta < 0 {
+								delta = -delta
+							}
+							if tt.wrap && delta == size-1 {
+								delta = 1
+							}
+							apart += delta
+						}
+					}
+					adjacent := differ == 1 && apart == 1
+					if linked := links[[2]routers.RouterId{routers.RouterId(a), routers.RouterId(b)}]; linked != adjacent {
+						t.Errorf("routers %v and %v linked = %v, want %v", coords[a], coords[b], linked, adjacent)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		params Params
+	}{
+		{"Unknown", Params{Size: 4}},
+		{"Unknown with a dimension", Params{Dimension: 3}},
+		{"Butterfly", Params{Dimension: 64}},
+		{"Torus", Params{Size: 2, Dimension: 100}},
+		{"Hypercube", Params{Dimension: 200}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Generate(tt.name, tt.params); err == nil {
+				t.Errorf("Generate(%s, %+v) should fail", tt.name, tt.params)
+			}
+		})
+	}
+}
+
+func TestGeneratorSizeErrors(t *testing.T) {
+	huge := ^uint(0)
+	tests := []struct {
+		name     string
+		generate func() (routers.Template, error)
+	}{
+		{"Line", func() (routers.Template, error) { return Line(huge) }},
+		{"Ring", func() (routers.Template, error) { return Ring(huge) }},
+		{"Star", func() (routers.Template, error) { return Star(huge) }},
+		{"Fully_Connected", func() (routers.Template, error) { return FullyConnected(huge) }},
+		{"Mesh", func() (routers.Template, error) { return Mesh(2, 64) }},
+		{"Torus", func() (routers.Template, error) { return Torus(1<<32, 2) }},
+		{"Hypercube", func() (routers.Template, error) { return Hypercube(64) }},
+		{"Hypercube past the index", func() (routers.Template, error) { return Hypercube(63) }},
+		{"Hypercube with coordinates", func() (routers.Template, error) {
+			tmpl, _, err := HypercubeWithCoordinates(64)
+			return tmpl, err
+		}},
+		{"Cube_Connected_Cycles", func() (routers.Template, error) { return CubeConnectedCycles(64) }},
+		{"Butterfly", func() (routers.Template, error) { return Butterfly(64) }},
+		{"Wrap_Around_Butterfly", func() (routers.Template, error) { return WrapAroundButterfly(64) }},
+		{"Erdos_Renyi", func() (routers.Template, error) { return ErdosRenyi(huge, 0.5, RandomOptions{}) }},
+		{"Random_Regular", func() (routers.Template, error) { return RandomRegular(huge, 2, RandomOptions{}) }},
+		{"Random_Geometric", func() (routers.Template, error) {
+			tmpl, _, err := RandomGeometric(huge, 0.1, RandomOptions{})
+			return tmpl, err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tmpl, err := tt.generate(); err == nil {
+				t.Errorf("generated %v routers, want an error", len(tmpl))
+			}
+		})
+	}
+}